@@ -0,0 +1,229 @@
+package dfm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The Get... methods return the value at the given path, see Object.Get for
+// the path syntax. They return false if there is no such property or if it has
+// a different type.
+
+// GetInt returns an Int, Int64 or UInt64 value that fits into an int64.
+func (o *Object) GetInt(path string) (int64, bool) {
+	v, _ := o.Get(path)
+	switch v := v.(type) {
+	case Int:
+		return int64(v), true
+	case Int64:
+		return int64(v), true
+	case UInt64:
+		if v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// GetFloat returns a Float, Extended, Single, Currency or Date value.
+func (o *Object) GetFloat(path string) (float64, bool) {
+	v, _ := o.Get(path)
+	switch v := v.(type) {
+	case Float:
+		return float64(v), true
+	case Extended:
+		return v.Float64(), true
+	case Single:
+		return float64(v), true
+	case Currency:
+		return v.Float64(), true
+	case Date:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// GetString returns a String value.
+func (o *Object) GetString(path string) (string, bool) {
+	v, _ := o.Get(path)
+	s, ok := v.(String)
+	return string(s), ok
+}
+
+// GetBool returns a Bool value.
+func (o *Object) GetBool(path string) (bool, bool) {
+	v, _ := o.Get(path)
+	b, ok := v.(Bool)
+	return bool(b), ok
+}
+
+// GetIdent returns an Identifier value, e.g. "clRed" or "alTop".
+func (o *Object) GetIdent(path string) (string, bool) {
+	v, _ := o.Get(path)
+	id, ok := v.(Identifier)
+	return string(id), ok
+}
+
+// GetSet returns the identifiers in a Set value, e.g. "akLeft" and "akTop" for
+// [akLeft, akTop]. It returns false if the set contains other values.
+func (o *Object) GetSet(path string) ([]string, bool) {
+	v, _ := o.Get(path)
+	set, ok := v.(Set)
+	if !ok {
+		return nil, false
+	}
+	ids := make([]string, len(set))
+	for i := range set {
+		id, ok := set[i].(Identifier)
+		if !ok {
+			return nil, false
+		}
+		ids[i] = string(id)
+	}
+	return ids, true
+}
+
+// GetStrings returns the lines of a TStrings property like Memo1.Lines. Delphi
+// stores them in a Tuple of Strings with the property name plus ".Strings",
+// which is appended to the path if it is missing. Delphi does not write empty
+// TStrings, GetStrings returns false for them.
+func (o *Object) GetStrings(path string) ([]string, bool) {
+	v, _ := o.Get(stringsPath(path))
+	tuple, ok := v.(Tuple)
+	if !ok {
+		return nil, false
+	}
+	lines := make([]string, len(tuple))
+	for i := range tuple {
+		s, ok := tuple[i].(String)
+		if !ok {
+			return nil, false
+		}
+		lines[i] = string(s)
+	}
+	return lines, true
+}
+
+// GetBytes returns a Bytes value.
+func (o *Object) GetBytes(path string) ([]byte, bool) {
+	v, _ := o.Get(path)
+	b, ok := v.(Bytes)
+	return []byte(b), ok
+}
+
+// SetValue sets the value of the property at the given path, see Object.Get
+// for the path syntax. An existing property is changed in place. Otherwise a
+// new property is added to the object after its last property, before its
+// child objects, which is where Delphi writes properties. Child objects cannot
+// be set, add them to Properties directly.
+func (o *Object) SetValue(path string, value PropertyValue) error {
+	if _, ok := value.(*Object); ok {
+		return fmt.Errorf("dfm.Object.SetValue: %q: cannot set objects", path)
+	}
+	_, obj, name := lookup(o, path)
+	if name == "" {
+		return fmt.Errorf("dfm.Object.SetValue: %q is an object, not a property", path)
+	}
+	if prop := findProperty(obj, name); prop != nil {
+		prop.Value = value
+		return nil
+	}
+	at := 0
+	for i := range obj.Properties {
+		if _, isObject := obj.Properties[i].Value.(*Object); !isObject {
+			at = i + 1
+		}
+	}
+	obj.Properties = append(obj.Properties, Property{})
+	copy(obj.Properties[at+1:], obj.Properties[at:])
+	obj.Properties[at] = Property{Name: name, Value: value}
+	return nil
+}
+
+// SetInt sets the property at the given path to an Int, see SetValue.
+func (o *Object) SetInt(path string, i int64) error {
+	return o.SetValue(path, Int(i))
+}
+
+// SetFloat sets the property at the given path to a Float, see SetValue.
+func (o *Object) SetFloat(path string, f float64) error {
+	return o.SetValue(path, Float(f))
+}
+
+// SetString sets the property at the given path to a String, see SetValue.
+func (o *Object) SetString(path string, s string) error {
+	return o.SetValue(path, String(s))
+}
+
+// SetBool sets the property at the given path to a Bool, see SetValue.
+func (o *Object) SetBool(path string, b bool) error {
+	return o.SetValue(path, Bool(b))
+}
+
+// SetIdent sets the property at the given path to an Identifier, see
+// SetValue.
+func (o *Object) SetIdent(path string, id string) error {
+	return o.SetValue(path, Identifier(id))
+}
+
+// SetSet sets the property at the given path to a Set of Identifiers, see
+// SetValue.
+func (o *Object) SetSet(path string, ids ...string) error {
+	set := make(Set, len(ids))
+	for i := range ids {
+		set[i] = Identifier(ids[i])
+	}
+	return o.SetValue(path, set)
+}
+
+// SetStrings sets the lines of a TStrings property like Memo1.Lines, see
+// GetStrings. Setting no lines removes the property, like Delphi does.
+func (o *Object) SetStrings(path string, lines []string) error {
+	path = stringsPath(path)
+	if len(lines) == 0 {
+		o.Remove(path)
+		return nil
+	}
+	tuple := make(Tuple, len(lines))
+	for i := range lines {
+		tuple[i] = String(lines[i])
+	}
+	return o.SetValue(path, tuple)
+}
+
+// SetBytes sets the property at the given path to Bytes, see SetValue.
+func (o *Object) SetBytes(path string, b []byte) error {
+	return o.SetValue(path, Bytes(b))
+}
+
+// Remove deletes the property or child object at the given path, see
+// Object.Get for the path syntax. It returns false if there is no such
+// property or object. The top-level object o cannot be removed.
+func (o *Object) Remove(path string) bool {
+	parent, obj, name := lookup(o, path)
+	owner := obj
+	if name == "" {
+		if parent == nil {
+			return false
+		}
+		owner = parent
+	}
+	for i, prop := range owner.Properties {
+		child, isObject := prop.Value.(*Object)
+		if name == "" && child == obj ||
+			name != "" && !isObject && strings.EqualFold(prop.Name, name) {
+			owner.Properties = append(owner.Properties[:i], owner.Properties[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// stringsPath appends ".Strings" to the path of a TStrings property.
+func stringsPath(path string) string {
+	if strings.HasSuffix(strings.ToLower(path), ".strings") {
+		return path
+	}
+	return path + ".Strings"
+}
@@ -0,0 +1,133 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestTypedGetters(t *testing.T) {
+	obj, err := dfm.ParseString(`object Form1: TForm1
+  Left = 10
+  Big = 5000000000
+  Ratio = 0.5
+  Price = 12345c
+  Caption = 'Main'
+  Visible = False
+  Color = clRed
+  Anchors = [akLeft, akTop]
+  Data = {0102}
+  object Memo1: TMemo
+    Lines.Strings = (
+      'a'
+      'b')
+  end
+end`)
+	check.Eq(t, err, nil)
+
+	i, ok := obj.GetInt("Left")
+	check.Eq(t, ok, true)
+	check.Eq(t, i, int64(10))
+	i, ok = obj.GetInt("big")
+	check.Eq(t, ok, true)
+	check.Eq(t, i, int64(5000000000))
+	_, ok = obj.GetInt("Caption")
+	check.Eq(t, ok, false)
+
+	f, ok := obj.GetFloat("Ratio")
+	check.Eq(t, ok, true)
+	check.Eq(t, f, 0.5)
+	f, ok = obj.GetFloat("Price")
+	check.Eq(t, ok, true)
+	check.Eq(t, f, 1.2345)
+
+	s, ok := obj.GetString("Caption")
+	check.Eq(t, ok, true)
+	check.Eq(t, s, "Main")
+
+	b, ok := obj.GetBool("Visible")
+	check.Eq(t, ok, true)
+	check.Eq(t, b, false)
+
+	id, ok := obj.GetIdent("Color")
+	check.Eq(t, ok, true)
+	check.Eq(t, id, "clRed")
+
+	set, ok := obj.GetSet("Anchors")
+	check.Eq(t, ok, true)
+	check.Eq(t, set, []string{"akLeft", "akTop"})
+
+	data, ok := obj.GetBytes("Data")
+	check.Eq(t, ok, true)
+	check.Eq(t, data, []byte{1, 2})
+
+	lines, ok := obj.GetStrings("Memo1.Lines")
+	check.Eq(t, ok, true)
+	check.Eq(t, lines, []string{"a", "b"})
+	lines, ok = obj.GetStrings("Memo1.Lines.Strings")
+	check.Eq(t, ok, true)
+	check.Eq(t, lines, []string{"a", "b"})
+
+	_, ok = obj.GetString("Missing")
+	check.Eq(t, ok, false)
+	_, ok = obj.GetString("Memo1")
+	check.Eq(t, ok, false)
+}
+
+func TestSettersChangeInPlaceOrAddBeforeChildObjects(t *testing.T) {
+	obj := &dfm.Object{Name: "Form1", Type: "TForm1", Properties: []dfm.Property{
+		{Name: "Left", Value: dfm.Int(1)},
+		{Value: &dfm.Object{Name: "Memo1", Type: "TMemo"}},
+	}}
+	check.Eq(t, obj.SetInt("left", 5), nil)
+	check.Eq(t, obj.SetString("Caption", "Main"), nil)
+	check.Eq(t, obj.SetBool("Visible", true), nil)
+	check.Eq(t, obj.SetIdent("Color", "clRed"), nil)
+	check.Eq(t, obj.SetSet("Anchors", "akLeft", "akTop"), nil)
+	check.Eq(t, obj.SetFloat("Ratio", 0.5), nil)
+	check.Eq(t, obj.SetBytes("Data", []byte{1}), nil)
+	check.Eq(t, obj.SetStrings("Memo1.Lines", []string{"a"}), nil)
+
+	have, err := obj.PrintWithOptions(dfm.PrintOptions{Newline: "\n"})
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), `object Form1: TForm1
+  Left = 5
+  Caption = 'Main'
+  Visible = True
+  Color = clRed
+  Anchors = [akLeft, akTop]
+  Ratio = 0.500000000000000000
+  Data = {
+    01}
+  object Memo1: TMemo
+    Lines.Strings = (
+      'a')
+  end
+end
+`)
+
+	check.Neq(t, obj.SetInt("Memo1", 1), nil)
+	check.Neq(t, obj.SetValue("X", &dfm.Object{}), nil)
+
+	check.Eq(t, obj.SetStrings("Memo1.Lines", nil), nil)
+	_, ok := obj.Get("Memo1.Lines.Strings")
+	check.Eq(t, ok, false)
+}
+
+func TestRemove(t *testing.T) {
+	obj := &dfm.Object{Name: "Form1", Type: "TForm1", Properties: []dfm.Property{
+		{Name: "Left", Value: dfm.Int(1)},
+		{Name: "Top", Value: dfm.Int(2)},
+		{Value: &dfm.Object{Name: "Panel1", Type: "TPanel", Properties: []dfm.Property{
+			{Name: "Align", Value: dfm.Identifier("alTop")},
+		}}},
+	}}
+	check.Eq(t, obj.Remove("LEFT"), true)
+	check.Eq(t, obj.Remove("Left"), false)
+	check.Eq(t, obj.Remove("Panel1.Align"), true)
+	check.Eq(t, len(obj.Child("Panel1").Properties), 0)
+	check.Eq(t, obj.Remove("Panel1"), true)
+	check.Eq(t, obj.Remove(""), false)
+	check.Eq(t, obj.Properties, []dfm.Property{{Name: "Top", Value: dfm.Int(2)}})
+}
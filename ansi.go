@@ -9,18 +9,7 @@ func allASCII(code []byte) bool {
 	return true
 }
 
-func decodeWindowsANSI(b []byte) []rune {
-	out := make([]rune, 0, len(b))
-	for _, b := range b {
-		if b < 128 {
-			out = append(out, rune(b))
-		} else {
-			out = append(out, ansiToRune[b-128])
-		}
-	}
-	return out
-}
-
+// ansiToRune maps the bytes 0x80 to 0xFF of Windows-1252 to runes.
 var ansiToRune = [128]rune{
 	'€',
 	'�',
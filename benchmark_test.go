@@ -0,0 +1,63 @@
+package dfm_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/dfm"
+)
+
+// largeDFM generates a form with many controls, each with a bitmap, similar to
+// real-world forms which consist mostly of image data.
+func largeDFM(nonASCII string) []byte {
+	var b bytes.Buffer
+	b.WriteString("object Form1: TForm1\r\n  Caption = 'Form" + nonASCII + "'\r\n")
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&b, "  object Image%d: TImage\r\n", i)
+		fmt.Fprintf(&b, "    Left = %d\r\n    Top = %d\r\n", i*8, i*16)
+		b.WriteString("    Hint = 'Image " + nonASCII + "'\r\n")
+		b.WriteString("    Anchors = [akLeft, akTop]\r\n")
+		b.WriteString("    Picture.Data = {\r\n")
+		for line := 0; line < 64; line++ {
+			b.WriteString("      " + strings.Repeat("0123456789ABCDEF", 4) + "\r\n")
+		}
+		b.WriteString("      00}\r\n  end\r\n")
+	}
+	b.WriteString("end\r\n")
+	return b.Bytes()
+}
+
+func benchmarkParse(b *testing.B, code []byte) {
+	b.SetBytes(int64(len(code)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := dfm.ParseBytes(code); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseASCII(b *testing.B) {
+	benchmarkParse(b, largeDFM(""))
+}
+
+func BenchmarkParseUTF8(b *testing.B) {
+	benchmarkParse(b, append([]byte("\xEF\xBB\xBF"), largeDFM("äöü")...))
+}
+
+func BenchmarkParseANSI(b *testing.B) {
+	benchmarkParse(b, largeDFM("\xE4\xF6\xFC"))
+}
+
+func BenchmarkPrint(b *testing.B) {
+	obj, err := dfm.ParseBytes(largeDFM(""))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		obj.Print()
+	}
+}
@@ -0,0 +1,384 @@
+package dfm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"unicode/utf16"
+)
+
+// Binary DFM files are stored in Delphi's streaming format, the same format
+// that is used for form resources in compiled executables. A binary DFM file
+// starts with a Windows resource header followed by the signature "TPF0",
+// resources in executables start with the signature right away.
+
+var binarySignature = []byte("TPF0")
+
+// Value types as defined in Delphi's Classes.pas (TValueType).
+const (
+	vaNull       = 0
+	vaList       = 1
+	vaInt8       = 2
+	vaInt16      = 3
+	vaInt32      = 4
+	vaExtended   = 5
+	vaString     = 6
+	vaIdent      = 7
+	vaFalse      = 8
+	vaTrue       = 9
+	vaBinary     = 10
+	vaSet        = 11
+	vaLString    = 12
+	vaNil        = 13
+	vaCollection = 14
+	vaSingle     = 15
+	vaCurrency   = 16
+	vaDate       = 17
+	vaWString    = 18
+	vaInt64      = 19
+	vaUTF8String = 20
+	vaDouble     = 21
+)
+
+// Object filer flags as defined in Delphi's Classes.pas (TFilerFlag). They are
+// stored in the lower 4 bits of an optional prefix byte with the upper 4 bits
+// set.
+const (
+	ffInherited = 1
+	ffChildPos  = 2
+	ffInline    = 4
+)
+
+// isBinary reports whether code starts with a resource header or the binary
+// signature. Resource headers start with 0xFF, followed by the resource type
+// 0x0A. The UTF-16 byte order mark 0xFF,0xFE starts text code.
+func isBinary(code []byte) bool {
+	if len(code) > 0 && code[0] == 0xFF {
+		return !bytes.HasPrefix(code, utf16leBOM)
+	}
+	return bytes.HasPrefix(code, binarySignature)
+}
+
+func parseBinary(code []byte, opts ParseOptions) (*Object, error) {
+	cp, err := lookupCodePage(opts.CodePage)
+	if err != nil {
+		return nil, err
+	}
+	p := binaryParser{code: code, exactFloats: opts.ExactFloats, cp: cp}
+	if opts.Parents != nil {
+		if *opts.Parents == nil {
+			*opts.Parents = make(Parents)
+		}
+		p.parents = *opts.Parents
+	}
+	p.resourceHeader()
+	p.signature()
+	obj := p.object()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return obj, nil
+}
+
+type binaryParser struct {
+	code []byte
+	cur  int
+	err  error
+	// exactFloats makes Extended values stay Extended instead of Float.
+	exactFloats bool
+	// cp is the code page of ANSI strings.
+	cp *codePage
+	// parents is nil unless the caller asked for them.
+	parents Parents
+}
+
+func (p *binaryParser) errorf(format string, a ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf(
+			"binary DFM: "+format+" at offset %d",
+			append(a, p.cur)...,
+		)
+	}
+}
+
+// resourceHeader skips the Windows resource header if there is one. It looks
+// like this:
+//
+//     FF 0A 00             resource type RT_RCDATA
+//     <name> 00            upper-case resource name, zero-terminated
+//     30 10                memory flags
+//     <4 byte size>        size of the resource data following the header
+func (p *binaryParser) resourceHeader() {
+	if len(p.code) == 0 || p.code[0] != 0xFF {
+		return
+	}
+	if !bytes.HasPrefix(p.code, []byte{0xFF, 0x0A, 0x00}) {
+		p.errorf("invalid resource header")
+		return
+	}
+	p.cur = 3
+	end := bytes.IndexByte(p.code[p.cur:], 0)
+	if end == -1 {
+		p.errorf("unterminated resource name")
+		return
+	}
+	p.cur += end + 1
+	p.read(2)
+	size := p.uint32()
+	if p.err == nil && uint64(p.cur)+uint64(size) > uint64(len(p.code)) {
+		p.errorf("resource size %d exceeds the file size", size)
+	}
+}
+
+func (p *binaryParser) signature() {
+	if !bytes.Equal(p.read(len(binarySignature)), binarySignature) {
+		p.cur -= len(binarySignature)
+		p.errorf("signature TPF0 expected")
+	}
+}
+
+func (p *binaryParser) object() *Object {
+	if p.err != nil {
+		return nil
+	}
+
+	var obj Object
+
+	if p.cur < len(p.code) && p.code[p.cur]&0xF0 == 0xF0 {
+		flags := p.byte() & 0x0F
+		if flags&ffInherited != 0 {
+			obj.Kind = Inherited
+		} else if flags&ffInline != 0 {
+			obj.Kind = Inline
+		}
+		if flags&ffChildPos != 0 {
+			index, ok := p.value().(Int)
+			if !ok {
+				p.errorf("integer expected as child position")
+				return nil
+			}
+			if Int(int(index)) != index {
+				p.errorf("child position %d does not fit into an int", index)
+				return nil
+			}
+			obj.HasIndex = true
+			obj.Index = int(index)
+		}
+	}
+
+	obj.Type = p.shortString()
+	obj.Name = p.shortString()
+
+	for !p.endOfList() {
+		obj.Properties = append(obj.Properties, p.property())
+	}
+	for !p.endOfList() {
+		child := p.object()
+		if child != nil {
+			obj.Properties = append(obj.Properties, Property{
+				Name:  child.Name,
+				Value: child,
+			})
+		}
+	}
+	if p.parents != nil {
+		p.parents.add(&obj)
+	}
+
+	return &obj
+}
+
+func (p *binaryParser) property() Property {
+	return Property{
+		Name:  p.shortString(),
+		Value: p.value(),
+	}
+}
+
+// endOfList consumes the list terminator (vaNull) if it is next. It also
+// returns true if an error occurred so that loops over lists terminate.
+func (p *binaryParser) endOfList() bool {
+	if p.err != nil {
+		return true
+	}
+	if p.cur >= len(p.code) {
+		p.errorf("premature end of file")
+		return true
+	}
+	if p.code[p.cur] == vaNull {
+		p.cur++
+		return true
+	}
+	return false
+}
+
+func (p *binaryParser) value() PropertyValue {
+	if p.err != nil {
+		return nil
+	}
+
+	typ := p.byte()
+	if p.err != nil {
+		return nil
+	}
+	switch typ {
+	case vaList:
+		tuple := Tuple{}
+		for !p.endOfList() {
+			tuple = append(tuple, p.value())
+		}
+		return tuple
+	case vaInt8:
+		return Int(int8(p.byte()))
+	case vaInt16:
+		return Int(int16(p.uint16()))
+	case vaInt32:
+		return Int(int32(p.uint32()))
+	case vaInt64:
+		n := int64(p.uint64())
+		if math.MinInt32 <= n && n <= math.MaxInt32 {
+			// Delphi would have used a smaller encoding, keep this one.
+			return Int64(n)
+		}
+		return Int(n)
+	case vaExtended:
+		b := p.read(10)
+		if p.err != nil {
+			return nil
+		}
+		x := extendedFromBytes(b)
+		if p.exactFloats {
+			return x
+		}
+		return Float(x.Float64())
+	case vaSingle:
+		return Single(math.Float32frombits(p.uint32()))
+	case vaCurrency:
+		// Currency values are stored as 64 bit integers, scaled by 10000.
+		return Currency(int64(p.uint64()))
+	case vaDate:
+		return Date(math.Float64frombits(p.uint64()))
+	case vaDouble:
+		return Float(math.Float64frombits(p.uint64()))
+	case vaString:
+		return String(p.cp.decode(p.read(int(p.byte()))))
+	case vaLString:
+		return String(p.cp.decode(p.read(int(p.uint32()))))
+	case vaWString:
+		data := p.read(2 * int(p.uint32()))
+		utf := make([]uint16, len(data)/2)
+		for i := range utf {
+			utf[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+		return String(utf16.Decode(utf))
+	case vaUTF8String:
+		return String(p.read(int(p.uint32())))
+	case vaIdent:
+		return Identifier(p.shortString())
+	case vaFalse:
+		return Bool(false)
+	case vaTrue:
+		return Bool(true)
+	case vaNil:
+		return Identifier("nil")
+	case vaNull:
+		return Identifier("Null")
+	case vaSet:
+		set := Set{}
+		for {
+			s := p.shortString()
+			if s == "" || p.err != nil {
+				break
+			}
+			set = append(set, Identifier(s))
+		}
+		return set
+	case vaBinary:
+		b := p.read(int(p.uint32()))
+		return Bytes(append([]byte{}, b...))
+	case vaCollection:
+		items := Items{}
+		for !p.endOfList() {
+			// Items may be preceded by an index, which Delphi writes for
+			// "item [n]" in text DFMs. Delphi's TReader ignores it when
+			// loading collections and Items has no place for it, so we skip
+			// it as well.
+			switch p.code[p.cur] {
+			case vaInt8, vaInt16, vaInt32:
+				p.value()
+			}
+			if p.byte() != vaList {
+				p.cur--
+				p.errorf("collection item expected")
+				return nil
+			}
+			item := []Property{}
+			for !p.endOfList() {
+				item = append(item, p.property())
+			}
+			items = append(items, item)
+		}
+		return items
+	default:
+		p.cur--
+		p.errorf("unknown value type %d", typ)
+		return nil
+	}
+}
+
+// shortString reads a string that is prefixed with its length as a single
+// byte. These are used for names, types and identifiers, which are stored in
+// UTF-8.
+func (p *binaryParser) shortString() string {
+	return string(p.read(int(p.byte())))
+}
+
+func (p *binaryParser) byte() byte {
+	b := p.read(1)
+	if len(b) == 0 {
+		return 0
+	}
+	return b[0]
+}
+
+func (p *binaryParser) uint16() uint16 {
+	b := p.read(2)
+	if len(b) < 2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (p *binaryParser) uint32() uint32 {
+	b := p.read(4)
+	if len(b) < 4 {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(b)
+}
+
+func (p *binaryParser) uint64() uint64 {
+	b := p.read(8)
+	if len(b) < 8 {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(b)
+}
+
+// read returns the next n bytes. If there are not enough bytes left, the error
+// is set and nil is returned. The length n is checked against the rest of the
+// code before anything is allocated, since it might come from a corrupt length
+// field.
+func (p *binaryParser) read(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(p.code)-p.cur {
+		p.errorf("premature end of file")
+		return nil
+	}
+	b := p.code[p.cur : p.cur+n]
+	p.cur += n
+	return b
+}
@@ -0,0 +1,335 @@
+package dfm_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+// binaryDFM helps building binary DFM streams for tests. Strings are written as
+// short strings, integers in little endian byte order.
+type binaryDFM struct {
+	bytes.Buffer
+}
+
+func (b *binaryDFM) add(data ...interface{}) *binaryDFM {
+	for _, d := range data {
+		switch d := d.(type) {
+		case string:
+			b.WriteByte(byte(len(d)))
+			b.WriteString(d)
+		case int:
+			b.WriteByte(byte(d))
+		case []byte:
+			b.Write(d)
+		default:
+			binary.Write(b, binary.LittleEndian, d)
+		}
+	}
+	return b
+}
+
+func TestParseBinaryDFM(t *testing.T) {
+	var b binaryDFM
+	b.add([]byte("TPF0"), "TForm1", "Form1")
+	b.add("I8", 2, int8(-5))
+	b.add("I16", 3, int16(1000))
+	b.add("I32", 4, int32(100000))
+	b.add("I64", 19, int64(1)<<40)
+	// 1.5 as 80 bit extended: mantissa 0xC000000000000000, exponent 16383.
+	b.add("Ext", 5, uint64(0xC000000000000000), uint16(16383))
+	b.add("Sgl", 15, float32(0.25))
+	b.add("Cur", 16, int64(123400))
+	b.add("Dat", 17, float64(39043.5))
+	b.add("Dbl", 21, float64(-2.5))
+	b.add("S", 6, "abc")
+	b.add("L", 12, int32(3), []byte("d\xE4f"))
+	b.add("W", 18, int32(2), uint16(0xE4), uint16(0x20AC))
+	b.add("U", 20, int32(2), []byte("ü"))
+	b.add("Id", 7, "clRed")
+	b.add("No", 8)
+	b.add("Yes", 9)
+	b.add("Nil", 13)
+	b.add("Null", 0)
+	b.add("Anchors", 11, "akLeft", "akTop", "")
+	b.add("Size", 1, 2, int8(1), 2, int8(2), 0)
+	b.add("Data", 10, int32(3), []byte{1, 2, 3})
+	b.add("Columns", 14, 1, "Width", 2, int8(5), 0, 1, 0, 0)
+	b.add(0)
+	b.add(0xF0|1|2, 2, int8(3), "TPanel", "Panel1", 0, 0)
+	b.add(0xF0|4, "TFrame", "", 0, 0)
+	b.add(0)
+
+	obj, err := dfm.ParseBytes(b.Bytes())
+	check.Eq(t, err, nil)
+	check.Eq(t, obj, &dfm.Object{
+		Name: "Form1",
+		Type: "TForm1",
+		Properties: []dfm.Property{
+			{Name: "I8", Value: dfm.Int(-5)},
+			{Name: "I16", Value: dfm.Int(1000)},
+			{Name: "I32", Value: dfm.Int(100000)},
+			{Name: "I64", Value: dfm.Int(1 << 40)},
+			{Name: "Ext", Value: dfm.Float(1.5)},
+			{Name: "Sgl", Value: dfm.Single(0.25)},
+			{Name: "Cur", Value: dfm.Currency(123400)},
+			{Name: "Dat", Value: dfm.Date(39043.5)},
+			{Name: "Dbl", Value: dfm.Float(-2.5)},
+			{Name: "S", Value: dfm.String("abc")},
+			{Name: "L", Value: dfm.String("däf")},
+			{Name: "W", Value: dfm.String("ä€")},
+			{Name: "U", Value: dfm.String("ü")},
+			{Name: "Id", Value: dfm.Identifier("clRed")},
+			{Name: "No", Value: dfm.Bool(false)},
+			{Name: "Yes", Value: dfm.Bool(true)},
+			{Name: "Nil", Value: dfm.Identifier("nil")},
+			{Name: "Null", Value: dfm.Identifier("Null")},
+			{Name: "Anchors", Value: dfm.Set{
+				dfm.Identifier("akLeft"),
+				dfm.Identifier("akTop"),
+			}},
+			{Name: "Size", Value: dfm.Tuple{dfm.Int(1), dfm.Int(2)}},
+			{Name: "Data", Value: dfm.Bytes{1, 2, 3}},
+			{Name: "Columns", Value: dfm.Items{
+				{{Name: "Width", Value: dfm.Int(5)}},
+				{},
+			}},
+			{Name: "Panel1", Value: &dfm.Object{
+				Name:       "Panel1",
+				Type:       "TPanel",
+				Kind:       dfm.Inherited,
+				HasIndex:   true,
+				Index:      3,
+				Properties: nil,
+			}},
+			{Name: "", Value: &dfm.Object{
+				Type: "TFrame",
+				Kind: dfm.Inline,
+			}},
+		},
+	})
+}
+
+func TestParseBinaryDFMWithResourceHeader(t *testing.T) {
+	var data binaryDFM
+	data.add([]byte("TPF0"), "TForm1", "Form1", 0, 0)
+
+	var b binaryDFM
+	b.add([]byte{0xFF, 0x0A, 0x00}, []byte("TFORM1\x00"), uint16(0x1030))
+	b.add(uint32(data.Len()), data.Bytes())
+
+	obj, err := dfm.ParseBytes(b.Bytes())
+	check.Eq(t, err, nil)
+	check.Eq(t, obj, &dfm.Object{Name: "Form1", Type: "TForm1"})
+}
+
+func TestParseBinaryExtendedSpecialValues(t *testing.T) {
+	var b binaryDFM
+	b.add([]byte("TPF0"), "T", "")
+	b.add("Zero", 5, uint64(0), uint16(0))
+	b.add("MinusInf", 5, uint64(1)<<63, uint16(0xFFFF))
+	b.add("Third", 5, uint64(0xAAAAAAAAAAAAAAAB), uint16(16381))
+	b.add(0, 0)
+
+	obj, err := dfm.ParseBytes(b.Bytes())
+	check.Eq(t, err, nil)
+	check.Eq(t, len(obj.Properties), 3)
+	check.EqExact(t, obj.Properties[0].Value, dfm.Float(0))
+	check.Eq(t, math.IsInf(float64(obj.Properties[1].Value.(dfm.Float)), -1), true)
+	check.EqExact(t, obj.Properties[2].Value, dfm.Float(1.0/3))
+}
+
+func TestParseBinaryCollectionItemsWithIndex(t *testing.T) {
+	var b binaryDFM
+	b.add([]byte("TPF0"), "TForm1", "Form1")
+	b.add("Columns", 14)
+	b.add(2, int8(0), 1, "Width", 2, int8(5), 0)
+	b.add(3, int16(300), 1, 0)
+	b.add(4, int32(70000), 1, "Width", 2, int8(7), 0)
+	b.add(1, 0)
+	b.add(0, 0, 0)
+
+	obj, err := dfm.ParseBytes(b.Bytes())
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Properties, []dfm.Property{
+		{Name: "Columns", Value: dfm.Items{
+			{{Name: "Width", Value: dfm.Int(5)}},
+			{},
+			{{Name: "Width", Value: dfm.Int(7)}},
+			{},
+		}},
+	})
+
+	b.Reset()
+	b.add([]byte("TPF0"), "T", "", "Columns", 14, 6, "abc", 0, 0, 0)
+	_, err = dfm.ParseBytes(b.Bytes())
+	check.Neq(t, err, nil)
+}
+
+func TestBinaryLengthsBeyondTheEndAreErrors(t *testing.T) {
+	// The length fields claim up to 2 GB of data, which must not be allocated.
+	for _, typ := range []int{12, 18, 20, 10} {
+		var b binaryDFM
+		b.add([]byte("TPF0"), "T", "", "A", typ, []byte{0xFF, 0xFF, 0xFF, 0x7F})
+		_, err := dfm.ParseBytes(b.Bytes())
+		check.Neq(t, err, nil, typ)
+	}
+	var b binaryDFM
+	b.add([]byte("TPF0"), "T", "", "A", 6, 200, "abc")
+	_, err := dfm.ParseBytes(b.Bytes())
+	check.Neq(t, err, nil)
+}
+
+func TestTruncatedBinaryValuesAreErrors(t *testing.T) {
+	for _, typ := range []int{2, 3, 4, 5, 15, 16, 17, 19, 21} {
+		var b binaryDFM
+		b.add([]byte("TPF0"), "T", "", "A", typ)
+		_, err := dfm.ParseBytes(b.Bytes())
+		check.Neq(t, err, nil, typ)
+	}
+}
+
+func TestWriteBinaryDFM(t *testing.T) {
+	obj := &dfm.Object{
+		Name: "Form1",
+		Type: "TForm1",
+		Properties: []dfm.Property{
+			{Value: &dfm.Object{
+				Name:     "Panel1",
+				Type:     "TPanel",
+				Kind:     dfm.Inherited,
+				HasIndex: true,
+				Index:    3,
+			}},
+			{Name: "I8", Value: dfm.Int(-128)},
+			{Name: "I16", Value: dfm.Int(128)},
+			{Name: "I32", Value: dfm.Int(-32769)},
+			{Name: "Ext", Value: dfm.Float(1.5)},
+			{Name: "S", Value: dfm.String("abc")},
+			{Name: "W", Value: dfm.String("ä")},
+			{Name: "U", Value: dfm.String("Hällo")},
+			{Name: "Id", Value: dfm.Identifier("clRed")},
+			{Name: "Nil", Value: dfm.Identifier("nil")},
+			{Name: "Yes", Value: dfm.Bool(true)},
+			{Name: "Anchors", Value: dfm.Set{dfm.Identifier("akLeft")}},
+			{Name: "Size", Value: dfm.Tuple{dfm.Int(1)}},
+			{Name: "Data", Value: dfm.Bytes{0xAB}},
+			{Name: "Columns", Value: dfm.Items{
+				{{Name: "Width", Value: dfm.Int(5)}},
+			}},
+		},
+	}
+
+	var want binaryDFM
+	want.add([]byte("TPF0"), "TForm1", "Form1")
+	want.add("I8", 2, int8(-128))
+	want.add("I16", 3, int16(128))
+	want.add("I32", 4, int32(-32769))
+	want.add("Ext", 5, uint64(0xC000000000000000), uint16(16383))
+	want.add("S", 6, "abc")
+	// Delphi writes UTF-8 if it is shorter than UTF-16.
+	want.add("W", 18, int32(1), uint16(0xE4))
+	want.add("U", 20, int32(6), []byte("Hällo"))
+	want.add("Id", 7, "clRed")
+	want.add("Nil", 13)
+	want.add("Yes", 9)
+	want.add("Anchors", 11, "akLeft", 0)
+	want.add("Size", 1, 2, int8(1), 0)
+	want.add("Data", 10, int32(1), []byte{0xAB})
+	want.add("Columns", 14, 1, "Width", 2, int8(5), 0, 0)
+	want.add(0)
+	want.add(0xF0|1|2, 2, int8(3), "TPanel", "Panel1", 0, 0)
+	want.add(0)
+
+	var have bytes.Buffer
+	check.Eq(t, obj.WriteBinaryTo(&have), nil)
+	check.Eq(t, have.Bytes(), want.Bytes())
+}
+
+func TestTextToBinaryToTextRoundTrip(t *testing.T) {
+	code := `inherited Form1: TForm1
+  Left = 100000
+  Big = 5000000000
+  Scale = -0.125000000000000000
+  Huge = 1E20
+  Ratio = 0.100000001490116s
+  Price = 123400c
+  Date = 45000.5d
+  Caption = 'The '#39'Laser'#39' '#8364
+  Long = 
+    'xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx' +
+    'xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx' +
+    'xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx' +
+    'xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx' +
+    'x'
+  Anchors = []
+  Event = Module.Action
+  Value = Null
+  Empty = <>
+  inline Frame: TFrame [2]
+    Sub = (
+      1
+      'a')
+  end
+  object TMenuItem
+  end
+end
+`
+	code = strings.Replace(code, "\n", "\r\n", -1)
+	obj, err := dfm.ParseString(code)
+	check.Eq(t, err, nil)
+
+	var bin bytes.Buffer
+	check.Eq(t, obj.WriteBinaryResourceTo(&bin), nil)
+	check.Eq(t, bin.Bytes()[:10], []byte("\xFF\x0A\x00TFORM1\x00"))
+	obj, err = dfm.ParseBytes(bin.Bytes())
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.String(), code)
+}
+
+func TestObjectsCanOnlyBeWrittenAsTopLevelProperties(t *testing.T) {
+	obj := prop("_", dfm.Tuple{&dfm.Object{Name: "Child"}})
+	err := obj.WriteBinaryTo(&bytes.Buffer{})
+	check.Neq(t, err, nil)
+}
+
+func TestBinaryIntegersKeepTheirWidth(t *testing.T) {
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{
+		{Name: "Small", Value: dfm.Int(1)},
+		{Name: "Forced", Value: dfm.Int64(1)},
+		{Name: "Big", Value: dfm.Int(-5000000000)},
+		{Name: "Unsigned", Value: dfm.UInt64(math.MaxInt64)},
+	}}
+	var bin bytes.Buffer
+	check.Eq(t, obj.WriteBinaryTo(&bin), nil)
+	check.Eq(t, bin.Bytes(), []byte("TPF0\x02TA\x00"+
+		"\x05Small\x02\x01"+
+		"\x06Forced\x13\x01\x00\x00\x00\x00\x00\x00\x00"+
+		"\x03Big\x13\x00\x0E\xFA\xD5\xFE\xFF\xFF\xFF"+
+		"\x08Unsigned\x13\xFF\xFF\xFF\xFF\xFF\xFF\xFF\x7F"+
+		"\x00\x00"))
+
+	parsed, err := dfm.ParseBytes(bin.Bytes())
+	check.Eq(t, err, nil)
+	check.Eq(t, parsed.Properties, []dfm.Property{
+		{Name: "Small", Value: dfm.Int(1)},
+		{Name: "Forced", Value: dfm.Int64(1)},
+		{Name: "Big", Value: dfm.Int(-5000000000)},
+		// Binary DFMs do not know unsigned integers.
+		{Name: "Unsigned", Value: dfm.Int64(math.MaxInt64)},
+	})
+	for i, typ := range []string{"dfm.Int", "dfm.Int64", "dfm.Int", "dfm.Int"} {
+		check.Eq(t, fmt.Sprintf("%T", parsed.Properties[i].Value), typ)
+	}
+}
+
+func TestUnsignedIntegersAboveInt64AreBinaryErrors(t *testing.T) {
+	obj := prop("X", dfm.UInt64(math.MaxInt64+1))
+	check.Neq(t, obj.WriteBinaryTo(&bytes.Buffer{}), nil)
+}
@@ -0,0 +1,273 @@
+package dfm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// WriteBinaryTo writes the Object as a binary DFM stream, starting with the
+// signature "TPF0", to the given io.Writer. This is the format that is used for
+// form resources in executables. Use WriteBinaryResourceTo to create a binary
+// DFM file.
+//
+// Values are encoded like Delphi's TWriter encodes them when ObjectTextToBinary
+// converts a text DFM. Integers use the smallest possible encoding. Strings
+// that contain only ASCII characters are written as short strings or, if
+// longer than 255 bytes, as long strings. All other strings are written as
+// UTF-8 strings if that is shorter than UTF-16, otherwise as wide (UTF-16)
+// strings. The identifiers nil and Null are written as their respective value
+// types.
+//
+// The output is not byte-identical to Delphi's in all cases. Floats are written
+// as 80 bit Extended values. A Float only holds 64 bits, so its last mantissa
+// bits are 0, where Delphi would have the exact Extended value of the decimal
+// text, e.g. 0.1 becomes CD CC CC CC CC CC CC CC FB 3F in Delphi but
+// 00 D0 CC CC CC CC CC CC FB 3F from a Float. To write the same bytes as
+// Delphi, parse text DFMs with ParseOptions.ExactFloats, which keeps floats as
+// Extended values. Binary DFMs only have signed 64 bit integers, so UInt64
+// values above math.MaxInt64 cannot be written and return an error.
+//
+// Properties that are child objects are written after all other properties,
+// which is the order that Delphi uses in text DFMs as well.
+func (o *Object) WriteBinaryTo(w io.Writer) error {
+	var p binaryPrinter
+	p.Write(binarySignature)
+	p.object(o)
+	if p.err != nil {
+		return p.err
+	}
+	_, err := w.Write(p.Bytes())
+	return err
+}
+
+// WriteBinaryResourceTo writes the Object as a binary DFM file. This is the
+// binary stream written by WriteBinaryTo, preceded by a Windows resource header
+// which is named after the Object's upper-case type, e.g. TFORM1.
+func (o *Object) WriteBinaryResourceTo(w io.Writer) error {
+	var data bytes.Buffer
+	if err := o.WriteBinaryTo(&data); err != nil {
+		return err
+	}
+	var p binaryPrinter
+	p.Write([]byte{0xFF, 0x0A, 0x00})
+	p.WriteString(strings.ToUpper(o.Type))
+	p.WriteByte(0)
+	p.uint16(0x1030)
+	p.uint32(uint32(data.Len()))
+	p.Write(data.Bytes())
+	_, err := w.Write(p.Bytes())
+	return err
+}
+
+type binaryPrinter struct {
+	bytes.Buffer
+	err error
+}
+
+func (p *binaryPrinter) errorf(format string, a ...interface{}) {
+	if p.err == nil {
+		p.err = fmt.Errorf("binary DFM: "+format, a...)
+	}
+}
+
+func (p *binaryPrinter) object(o *Object) {
+	var flags byte
+	if o.Kind == Inherited {
+		flags |= ffInherited
+	} else if o.Kind == Inline {
+		flags |= ffInline
+	}
+	if o.HasIndex {
+		flags |= ffChildPos
+	}
+	if flags != 0 {
+		p.WriteByte(0xF0 | flags)
+		if o.HasIndex {
+			p.integer(int64(o.Index))
+		}
+	}
+
+	p.shortString(o.Type)
+	p.shortString(o.Name)
+
+	for _, prop := range o.Properties {
+		if _, ok := prop.Value.(*Object); !ok {
+			p.property(prop)
+		}
+	}
+	p.WriteByte(vaNull)
+	for _, prop := range o.Properties {
+		if child, ok := prop.Value.(*Object); ok {
+			p.object(child)
+		}
+	}
+	p.WriteByte(vaNull)
+}
+
+func (p *binaryPrinter) property(prop Property) {
+	p.shortString(prop.Name)
+	p.value(prop.Value)
+}
+
+func (p *binaryPrinter) value(value PropertyValue) {
+	switch v := value.(type) {
+	case Int:
+		p.integer(int64(v))
+	case Int64:
+		p.WriteByte(vaInt64)
+		p.uint64(uint64(v))
+	case UInt64:
+		if v > math.MaxInt64 {
+			p.errorf("%d does not fit into a signed 64 bit integer", uint64(v))
+			return
+		}
+		p.WriteByte(vaInt64)
+		p.uint64(uint64(v))
+	case Float:
+		p.WriteByte(vaExtended)
+		p.Write(ExtendedFromFloat64(float64(v)).bytes())
+	case Extended:
+		p.WriteByte(vaExtended)
+		p.Write(v.bytes())
+	case Single:
+		p.WriteByte(vaSingle)
+		p.uint32(math.Float32bits(float32(v)))
+	case Currency:
+		p.WriteByte(vaCurrency)
+		p.uint64(uint64(v))
+	case Date:
+		p.WriteByte(vaDate)
+		p.uint64(math.Float64bits(float64(v)))
+	case Bool:
+		if v {
+			p.WriteByte(vaTrue)
+		} else {
+			p.WriteByte(vaFalse)
+		}
+	case String:
+		p.string(string(v))
+	case Identifier:
+		switch strings.ToLower(string(v)) {
+		case "nil":
+			p.WriteByte(vaNil)
+		case "null":
+			p.WriteByte(vaNull)
+		default:
+			p.WriteByte(vaIdent)
+			p.shortString(string(v))
+		}
+	case Set:
+		p.WriteByte(vaSet)
+		for _, elem := range v {
+			id, ok := elem.(Identifier)
+			if !ok {
+				p.errorf("set elements must be identifiers but have %T", elem)
+				return
+			}
+			p.shortString(string(id))
+		}
+		p.WriteByte(0)
+	case Tuple:
+		p.WriteByte(vaList)
+		for _, elem := range v {
+			p.value(elem)
+		}
+		p.WriteByte(vaNull)
+	case Bytes:
+		p.WriteByte(vaBinary)
+		p.uint32(uint32(len(v)))
+		p.Write(v)
+	case Items:
+		p.WriteByte(vaCollection)
+		for _, item := range v {
+			p.WriteByte(vaList)
+			for _, prop := range item {
+				p.property(prop)
+			}
+			p.WriteByte(vaNull)
+		}
+		p.WriteByte(vaNull)
+	case *Object:
+		p.errorf("object %q can only be a top-level property", v.Name)
+	default:
+		p.errorf("unhandled property type %T", v)
+	}
+}
+
+// integer writes n in the smallest encoding that can hold it.
+func (p *binaryPrinter) integer(n int64) {
+	if math.MinInt8 <= n && n <= math.MaxInt8 {
+		p.WriteByte(vaInt8)
+		p.WriteByte(byte(n))
+	} else if math.MinInt16 <= n && n <= math.MaxInt16 {
+		p.WriteByte(vaInt16)
+		p.uint16(uint16(n))
+	} else if math.MinInt32 <= n && n <= math.MaxInt32 {
+		p.WriteByte(vaInt32)
+		p.uint32(uint32(n))
+	} else {
+		p.WriteByte(vaInt64)
+		p.uint64(uint64(n))
+	}
+}
+
+func (p *binaryPrinter) string(s string) {
+	if isASCII(s) {
+		if len(s) <= 255 {
+			p.WriteByte(vaString)
+			p.WriteByte(byte(len(s)))
+		} else {
+			p.WriteByte(vaLString)
+			p.uint32(uint32(len(s)))
+		}
+		p.WriteString(s)
+	} else if utf := utf16.Encode([]rune(s)); len(s) < 2*len(utf) {
+		p.WriteByte(vaUTF8String)
+		p.uint32(uint32(len(s)))
+		p.WriteString(s)
+	} else {
+		p.WriteByte(vaWString)
+		p.uint32(uint32(len(utf)))
+		for _, u := range utf {
+			p.uint16(u)
+		}
+	}
+}
+
+// shortString writes s in UTF-8, prefixed with its length as a single byte.
+func (p *binaryPrinter) shortString(s string) {
+	if len(s) > 255 {
+		p.errorf("%q is longer than 255 bytes", s)
+		return
+	}
+	if !utf8.ValidString(s) {
+		p.errorf("%q is not valid UTF-8", s)
+		return
+	}
+	p.WriteByte(byte(len(s)))
+	p.WriteString(s)
+}
+
+func (p *binaryPrinter) uint16(n uint16) {
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], n)
+	p.Write(b[:])
+}
+
+func (p *binaryPrinter) uint32(n uint32) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], n)
+	p.Write(b[:])
+}
+
+func (p *binaryPrinter) uint64(n uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], n)
+	p.Write(b[:])
+}
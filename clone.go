@@ -0,0 +1,71 @@
+package dfm
+
+// Clone returns a deep copy of o. The copy shares no memory with o, changing
+// the properties, child objects, Sets, Tuples, Items or Bytes of one does not
+// affect the other. Objects parsed with ParseOptions.Lossless keep their
+// original code in the copy. Clone returns nil for a nil Object.
+func (o *Object) Clone() *Object {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	c.Properties = cloneProperties(o.Properties)
+	if o.source != nil {
+		c.source = o.source.clone()
+	}
+	return &c
+}
+
+// CloneValue returns a deep copy of the given value so later changes to the
+// original do not affect the copy. Objects are copied with Object.Clone.
+func CloneValue(value PropertyValue) PropertyValue {
+	switch v := value.(type) {
+	case *Object:
+		return v.Clone()
+	case Set:
+		if v == nil {
+			return v
+		}
+		c := make(Set, len(v))
+		for i := range v {
+			c[i] = CloneValue(v[i])
+		}
+		return c
+	case Tuple:
+		if v == nil {
+			return v
+		}
+		c := make(Tuple, len(v))
+		for i := range v {
+			c[i] = CloneValue(v[i])
+		}
+		return c
+	case Bytes:
+		if v == nil {
+			return v
+		}
+		return append(Bytes{}, v...)
+	case Items:
+		if v == nil {
+			return v
+		}
+		c := make(Items, len(v))
+		for i := range v {
+			c[i] = cloneProperties(v[i])
+		}
+		return c
+	default:
+		return v
+	}
+}
+
+func cloneProperties(props []Property) []Property {
+	if props == nil {
+		return nil
+	}
+	c := make([]Property, len(props))
+	for i := range props {
+		c[i] = Property{Name: props[i].Name, Value: CloneValue(props[i].Value)}
+	}
+	return c
+}
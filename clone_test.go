@@ -0,0 +1,83 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestCloneSharesNoMemory(t *testing.T) {
+	orig := &dfm.Object{
+		Name: "Form1",
+		Type: "TForm1",
+		Properties: []dfm.Property{
+			{Name: "Anchors", Value: dfm.Set{dfm.Identifier("akLeft")}},
+			{Name: "Size", Value: dfm.Tuple{dfm.Int(1), dfm.Set{dfm.Identifier("a")}}},
+			{Name: "Data", Value: dfm.Bytes{1, 2}},
+			{Name: "Columns", Value: dfm.Items{{{Name: "Width", Value: dfm.Int(5)}}}},
+			{Name: "Panel1", Value: &dfm.Object{
+				Name:       "Panel1",
+				Type:       "TPanel",
+				Properties: []dfm.Property{{Name: "Left", Value: dfm.Int(1)}},
+			}},
+		},
+	}
+	c := orig.Clone()
+	check.Eq(t, c, orig)
+
+	c.Name = "Form2"
+	c.Properties[0].Value.(dfm.Set)[0] = dfm.Identifier("akTop")
+	c.Properties[1].Value.(dfm.Tuple)[1].(dfm.Set)[0] = dfm.Identifier("b")
+	c.Properties[2].Value.(dfm.Bytes)[0] = 9
+	c.Properties[3].Value.(dfm.Items)[0][0].Value = dfm.Int(6)
+	c.Properties[4].Value.(*dfm.Object).Properties[0].Value = dfm.Int(2)
+	c.Properties = append(c.Properties[:1], dfm.Property{Name: "X", Value: dfm.Int(0)})
+
+	check.Eq(t, orig, &dfm.Object{
+		Name: "Form1",
+		Type: "TForm1",
+		Properties: []dfm.Property{
+			{Name: "Anchors", Value: dfm.Set{dfm.Identifier("akLeft")}},
+			{Name: "Size", Value: dfm.Tuple{dfm.Int(1), dfm.Set{dfm.Identifier("a")}}},
+			{Name: "Data", Value: dfm.Bytes{1, 2}},
+			{Name: "Columns", Value: dfm.Items{{{Name: "Width", Value: dfm.Int(5)}}}},
+			{Name: "Panel1", Value: &dfm.Object{
+				Name:       "Panel1",
+				Type:       "TPanel",
+				Properties: []dfm.Property{{Name: "Left", Value: dfm.Int(1)}},
+			}},
+		},
+	})
+}
+
+func TestCloneValue(t *testing.T) {
+	check.Eq(t, dfm.CloneValue(dfm.Int(5)), dfm.Int(5))
+	check.Eq(t, dfm.CloneValue(nil), nil)
+	check.Eq(t, dfm.CloneValue(dfm.Set(nil)), dfm.Set(nil))
+
+	b := dfm.Bytes{1}
+	c := dfm.CloneValue(b).(dfm.Bytes)
+	c[0] = 2
+	check.Eq(t, b, dfm.Bytes{1})
+
+	var nilObject *dfm.Object
+	check.Eq(t, nilObject.Clone() == nil, true)
+}
+
+func TestLosslessCloneKeepsOriginalCode(t *testing.T) {
+	code := "object A: TA\r\n" +
+		"  X =   1\r\n" +
+		"  Y = 'y'\r\n" +
+		"end\r\n"
+	obj, err := dfm.ParseStringWithOptions(code, dfm.ParseOptions{Lossless: true})
+	check.Eq(t, err, nil)
+	c := obj.Clone()
+	check.Eq(t, c.String(), code)
+	c.Properties[1].Value = dfm.String("z")
+	check.Eq(t, c.String(), "object A: TA\r\n"+
+		"  X =   1\r\n"+
+		"  Y = 'z'\r\n"+
+		"end\r\n")
+	check.Eq(t, obj.String(), code)
+}
@@ -0,0 +1,97 @@
+// dfmquery prints the objects and properties in DFM files that match a
+// selector, see dfm.ParseSelector for the syntax. Usage:
+//
+//     dfmquery [-count] selector file-or-folder...
+//
+// Folders are searched recursively for .dfm files. For example, this lists all
+// TDBEdits on tab sheets that have no DataField:
+//
+//     dfmquery "TTabSheet TDBEdit[DataField='']" src
+//
+// Each match is printed on a line like
+//
+//     src/Main.dfm: Form1.Pages.Tab1.Edit2: TDBEdit
+//     src/Main.dfm: Form1.Pages.Tab1.Edit1.DataField = 'ID'
+//
+// The exit code is 0 if there were matches, 1 if there were none and 2 if an
+// error occurred.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gonutz/dfm"
+)
+
+func main() {
+	count := flag.Bool("count", false, "only print the number of matches per file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: dfmquery [-count] selector file-or-folder...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	selector, err := dfm.ParseSelector(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var files []string
+	for _, arg := range flag.Args()[1:] {
+		err := filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// Files given explicitly are queried, whatever their extension.
+			if !info.IsDir() &&
+				(path == arg || strings.EqualFold(filepath.Ext(path), ".dfm")) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	exitCode := 1
+	for _, file := range files {
+		obj, err := dfm.ParseFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 2
+			continue
+		}
+		matches := selector.Query(obj)
+		if len(matches) > 0 && exitCode == 1 {
+			exitCode = 0
+		}
+		if *count {
+			fmt.Printf("%s: %d\n", file, len(matches))
+			continue
+		}
+		for _, m := range matches {
+			// Paths are relative to the top-level object, prefix its name to
+			// make them complete.
+			if obj.Name != "" {
+				if m.Path == "" {
+					m.Path = obj.Name
+				} else {
+					m.Path = obj.Name + "." + m.Path
+				}
+			}
+			fmt.Printf("%s: %s\n", file, m)
+		}
+	}
+	os.Exit(exitCode)
+}
@@ -0,0 +1,200 @@
+package dfm
+
+import (
+	"fmt"
+	"sync"
+	"unicode/utf8"
+)
+
+// codePage is a Windows code page for non-unicode text. Bytes below 0x80 are
+// ASCII. Single byte code pages map every other byte to one rune. Double byte
+// code pages (DBCS) have lead bytes that form a character together with the
+// next byte, the trail byte.
+type codePage struct {
+	id int
+	// high maps the bytes 0x80 to 0xFF to runes, lead bytes map to 0.
+	high *[128]rune
+	// dbcs has one line of 191 characters, for trail bytes 0x40 to 0xFE, for
+	// every lead byte. It is only set for double byte code pages.
+	dbcs string
+
+	once sync.Once
+	// double maps lead byte and trail byte to a rune, it is created from dbcs
+	// on first use. See doubleIndex.
+	double []rune
+	// encoding maps runes to their 1 or 2 byte encoding, it is created on
+	// first use.
+	encoding map[rune][2]byte
+}
+
+var (
+	cp874  = &codePage{id: 874, high: &cp874High}
+	cp932  = &codePage{id: 932, high: &cp932High, dbcs: cp932DBCS}
+	cp936  = &codePage{id: 936, high: &cp936High, dbcs: cp936DBCS}
+	cp949  = &codePage{id: 949, high: &cp949High, dbcs: cp949DBCS}
+	cp950  = &codePage{id: 950, high: &cp950High, dbcs: cp950DBCS}
+	cp1250 = &codePage{id: 1250, high: &cp1250High}
+	cp1251 = &codePage{id: 1251, high: &cp1251High}
+	cp1252 = &codePage{id: 1252, high: &ansiToRune}
+	cp1253 = &codePage{id: 1253, high: &cp1253High}
+	cp1254 = &codePage{id: 1254, high: &cp1254High}
+	cp1255 = &codePage{id: 1255, high: &cp1255High}
+	cp1256 = &codePage{id: 1256, high: &cp1256High}
+	cp1257 = &codePage{id: 1257, high: &cp1257High}
+	cp1258 = &codePage{id: 1258, high: &cp1258High}
+)
+
+// lookupCodePage returns the Windows code page with the given number, 0 is
+// Windows-1252.
+func lookupCodePage(id int) (*codePage, error) {
+	switch id {
+	case 0, 1252:
+		return cp1252, nil
+	case 874:
+		return cp874, nil
+	case 932:
+		return cp932, nil
+	case 936:
+		return cp936, nil
+	case 949:
+		return cp949, nil
+	case 950:
+		return cp950, nil
+	case 1250:
+		return cp1250, nil
+	case 1251:
+		return cp1251, nil
+	case 1253:
+		return cp1253, nil
+	case 1254:
+		return cp1254, nil
+	case 1255:
+		return cp1255, nil
+	case 1256:
+		return cp1256, nil
+	case 1257:
+		return cp1257, nil
+	case 1258:
+		return cp1258, nil
+	}
+	return nil, fmt.Errorf("dfm: unsupported code page %d", id)
+}
+
+func (c *codePage) init() {
+	c.once.Do(func() {
+		if c.dbcs != "" {
+			c.double = make([]rune, 0, 128*191)
+			lines := []rune(c.dbcs)
+			line := 0
+			for lead := 0x80; lead <= 0xFF; lead++ {
+				if c.high[lead-0x80] == 0 {
+					c.double = append(c.double, lines[line*191:(line+1)*191]...)
+					line++
+				} else {
+					for i := 0; i < 191; i++ {
+						c.double = append(c.double, utf8.RuneError)
+					}
+				}
+			}
+		}
+
+		c.encoding = make(map[rune][2]byte)
+		for i, r := range c.high {
+			if r != 0 && r != utf8.RuneError {
+				c.encoding[r] = [2]byte{byte(0x80 + i)}
+			}
+		}
+		for i, r := range c.double {
+			lead, trail := byte(0x80+i/191), byte(0x40+i%191)
+			if _, ok := c.encoding[r]; !ok && r != utf8.RuneError {
+				// Some characters have more than one encoding, the first one
+				// is used.
+				c.encoding[r] = [2]byte{lead, trail}
+			}
+		}
+	})
+}
+
+// isLead reports whether b is a lead byte of a double byte character.
+func (c *codePage) isLead(b byte) bool {
+	return b >= 0x80 && c.high[b-0x80] == 0
+}
+
+// decodeRune decodes the first character in b and returns its rune and its
+// size in bytes. Undefined characters are returned as utf8.RuneError. A lead
+// byte without a valid trail byte is decoded as utf8.RuneError of size 1.
+func (c *codePage) decodeRune(b []byte) (r rune, size int) {
+	if b[0] < 0x80 {
+		return rune(b[0]), 1
+	}
+	if r := c.high[b[0]-0x80]; r != 0 {
+		return r, 1
+	}
+	if len(b) < 2 || b[1] < 0x40 || b[1] == 0xFF {
+		return utf8.RuneError, 1
+	}
+	c.init()
+	return c.double[int(b[0]-0x80)*191+int(b[1]-0x40)], 2
+}
+
+// decode converts code in this code page to runes.
+func (c *codePage) decode(code []byte) []rune {
+	out := make([]rune, 0, len(code))
+	for len(code) > 0 {
+		r, n := c.decodeRune(code)
+		out = append(out, r)
+		code = code[n:]
+	}
+	return out
+}
+
+// runeCount returns the number of characters in code.
+func (c *codePage) runeCount(code []byte) int {
+	if c.dbcs == "" {
+		return len(code)
+	}
+	n := 0
+	for len(code) > 0 {
+		_, size := c.decodeRune(code)
+		code = code[size:]
+		n++
+	}
+	return n
+}
+
+// canEncode reports whether r exists in this code page.
+func (c *codePage) canEncode(r rune) bool {
+	if r < 0x80 {
+		return true
+	}
+	c.init()
+	_, ok := c.encoding[r]
+	return ok
+}
+
+// encode converts s to this code page. It returns false if s contains
+// characters that do not exist in this code page.
+func (c *codePage) encode(s string) ([]byte, bool) {
+	c.init()
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 0x80 {
+			out = append(out, byte(r))
+			continue
+		}
+		b, ok := c.encoding[r]
+		if !ok {
+			return nil, false
+		}
+		if b[1] == 0 {
+			out = append(out, b[0])
+		} else {
+			out = append(out, b[0], b[1])
+		}
+	}
+	return out, true
+}
+
+func (c *codePage) String() string {
+	return fmt.Sprintf("Windows-%d", c.id)
+}
@@ -0,0 +1,130 @@
+package dfm_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestParseWithCodePage(t *testing.T) {
+	tests := []struct {
+		codePage int
+		code     string
+		want     string
+	}{
+		{0, "\xE4\x80", "ä€"},
+		{1252, "\xE4\x80", "ä€"},
+		{1250, "\x5A\x61\xBF\xF3\xB3\xE6", "Zażółć"},
+		{1251, "\xCF\xF0\xE8\xE2\xE5\xF2", "Привет"},
+		{874, "\xA1", "ก"},
+		// The trail byte of ソ is a backslash.
+		{932, "\x83\x5C\x93\xFA\x96\x7B\xB1", "ソ日本ｱ"},
+		{936, "\xD6\xD0\xCE\xC4\x80", "中文€"},
+		{949, "\xC7\xD1\xB1\xB9", "한국"},
+		{950, "\xA4\xA4\xA4\xE5", "中文"},
+		// A lead byte without trail byte is invalid.
+		{932, "\x93", "�"},
+	}
+	for _, test := range tests {
+		code := "object A: TA\r\n  Caption = '" + test.code + "'\r\n  X = 1\r\nend"
+		obj, err := dfm.ParseBytesWithOptions([]byte(code), dfm.ParseOptions{CodePage: test.codePage})
+		check.Eq(t, err, nil, test.codePage)
+		check.Eq(t, obj.Properties, []dfm.Property{
+			{Name: "Caption", Value: dfm.String(test.want)},
+			{Name: "X", Value: dfm.Int(1)},
+		}, test.codePage)
+	}
+}
+
+func TestCodePageAppliesToIdentifiersAndPositions(t *testing.T) {
+	var pos dfm.Positions
+	code := "object \x93\xFA\x96\x7B: TA\r\n  \x83\x5C = 1\r\nend"
+	obj, err := dfm.ParseBytesWithOptions([]byte(code), dfm.ParseOptions{
+		CodePage:  932,
+		Positions: &pos,
+	})
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Name, "日本")
+	check.Eq(t, obj.Properties[0].Name, "ソ")
+	check.Eq(t, pos.Properties[&obj.Properties[0]].Value.Start, dfm.Position{
+		Line:   2,
+		Col:    7,
+		Offset: 24,
+	})
+}
+
+func TestUnsupportedCodePage(t *testing.T) {
+	_, err := dfm.ParseBytesWithOptions([]byte("object A: TA\r\n  S = '\xE4'\r\nend"), dfm.ParseOptions{CodePage: 1})
+	check.Neq(t, err, nil)
+	_, err = dfm.Object{Type: "TA"}.PrintWithOptions(dfm.PrintOptions{CodePage: 1})
+	check.Neq(t, err, nil)
+}
+
+func TestDecoderUsesCodePage(t *testing.T) {
+	code := "object A: TA\r\n  S = '\x83\x5C\x93\xFA\x96\x7B'\r\nend"
+	// Double byte characters might be split across reads.
+	d := dfm.NewDecoderWithOptions(
+		iotest.OneByteReader(bytes.NewReader([]byte(code))),
+		dfm.ParseOptions{CodePage: 932},
+	)
+	var tokens []dfm.Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		check.Eq(t, err, nil)
+		if err != nil {
+			break
+		}
+		tokens = append(tokens, tok)
+	}
+	check.Eq(t, len(tokens), 4)
+	check.Eq(t, tokens[2], dfm.Value{Value: dfm.String("ソ日本")})
+}
+
+func TestBinaryANSIStringsUseCodePage(t *testing.T) {
+	var b binaryDFM
+	b.add([]byte("TPF0"), "TA", "", "S", 6, "\xCF\xF0\xE8\xE2\xE5\xF2", 0, 0)
+	obj, err := dfm.ParseBytesWithOptions(b.Bytes(), dfm.ParseOptions{CodePage: 1251})
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Properties[0].Value, dfm.String("Привет"))
+}
+
+func TestPrintRawStringsInCodePage(t *testing.T) {
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{
+		{Name: "S", Value: dfm.String("Zażółć €日\t")},
+	}}
+
+	have, err := obj.PrintWithOptions(dfm.PrintOptions{
+		Encoding:   dfm.ANSI,
+		CodePage:   1250,
+		RawStrings: true,
+	})
+	check.Eq(t, err, nil)
+	// 日 does not exist in Windows-1250 and is escaped, so is the tab.
+	check.Eq(t, string(have), "object TA\r\n  S = 'Za\xBF\xF3\xB3\xE6 \x80'#26085#9\r\nend\r\n")
+
+	have, err = obj.PrintWithOptions(dfm.PrintOptions{RawStrings: true})
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "\xEF\xBB\xBFobject TA\r\n  S = 'Zażółć €日'#9\r\nend\r\n")
+
+	have, err = obj.PrintWithOptions(dfm.PrintOptions{Encoding: dfm.ANSI, CodePage: 1250})
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "object TA\r\n  S = "+
+		"'Za'#380#243#322#263' '#8364#26085#9\r\nend\r\n")
+}
+
+func TestPrintANSIIdentifiersInCodePage(t *testing.T) {
+	obj := dfm.Object{Name: "日本", Type: "TA"}
+	have, err := obj.PrintWithOptions(dfm.PrintOptions{Encoding: dfm.ANSI, CodePage: 932})
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "object \x93\xFA\x96\x7B: TA\r\nend\r\n")
+
+	_, err = obj.PrintWithOptions(dfm.PrintOptions{Encoding: dfm.ANSI, CodePage: 1251})
+	check.Neq(t, err, nil)
+}
@@ -0,0 +1,225 @@
+package dfm
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Decoder reads text DFM code from a stream token by token, without building
+// the Object tree in memory. Use it to scan large files for a few properties.
+// The code is read in small chunks, only a single value has to fit into
+// memory.
+//
+// A typical loop looks like this:
+//
+//     d := dfm.NewDecoder(r)
+//     for {
+//         t, err := d.Token()
+//         if err == io.EOF {
+//             break
+//         }
+//         if err != nil {
+//             return err
+//         }
+//         switch t := t.(type) {
+//         case dfm.ObjectStart:
+//             if t.Type == "TImage" {
+//                 d.Skip() // Skip the whole image object.
+//             }
+//         case dfm.PropertyName:
+//             ...
+//         }
+//     }
+//
+// The encoding is detected like in DetectEncoding, but only from the first few
+// kilobytes of code, or it is set in ParseOptions.Encoding. ANSI code is
+// decoded in ParseOptions.CodePage, which is Windows-1252 by default.
+// Binary DFMs are not supported.
+type Decoder struct {
+	r    *bufio.Reader
+	p    *parser
+	err  error
+	opts ParseOptions
+	// depth is the number of objects that were started but not ended yet.
+	depth int
+	// inValue is true if the last token was a PropertyName, in that case the
+	// next token is its Value.
+	inValue bool
+}
+
+// Token is one of ObjectStart, PropertyName, Value or ObjectEnd.
+type Token interface {
+	isToken()
+}
+
+// ObjectStart starts an object. It is followed by the object's properties and
+// child objects and finally by an ObjectEnd.
+type ObjectStart struct {
+	Name     string
+	Type     string
+	Kind     ObjectKind
+	HasIndex bool
+	Index    int
+}
+
+// PropertyName is the name of a property, including all dots, e.g.
+// "Font.Height". It is always followed by the property's Value.
+type PropertyName string
+
+// Value is the value of the preceding PropertyName. Child objects are not
+// values, they are reported as ObjectStart and ObjectEnd instead.
+type Value struct {
+	Value PropertyValue
+}
+
+// ObjectEnd ends the last started object.
+type ObjectEnd struct{}
+
+func (ObjectStart) isToken()  {}
+func (PropertyName) isToken() {}
+func (Value) isToken()        {}
+func (ObjectEnd) isToken()    {}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return NewDecoderWithOptions(r, ParseOptions{})
+}
+
+// NewDecoderWithOptions is like NewDecoder but uses the given options. Only
+// ParseOptions.FileName, which appears in ParseErrors, ParseOptions.ExactFloats,
+// ParseOptions.Encoding and ParseOptions.CodePage are used. The Decoder does not
+// build Objects, so ParseOptions.Parents is not filled.
+func NewDecoderWithOptions(r io.Reader, opts ParseOptions) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), opts: opts}
+}
+
+// Token returns the next token in the stream. At the end of the input it
+// returns io.EOF. The stream can contain multiple top-level objects, each of
+// them is reported as an ObjectStart, followed by its contents and an
+// ObjectEnd. After an error, all calls to Token return the same error.
+func (d *Decoder) Token() (Token, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	if d.p == nil {
+		d.init()
+		if d.err != nil {
+			return nil, d.err
+		}
+	}
+	t, err := d.token()
+	if readErr := d.p.tokens.srcErr; readErr != nil && readErr != io.EOF {
+		err = readErr
+	}
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	return t, nil
+}
+
+// Skip skips tokens that are not of interest. If the last token was a
+// PropertyName, Skip skips its value. Otherwise it skips the rest of the
+// innermost object that is not ended yet, including its ObjectEnd. Calling
+// Skip right after an ObjectStart thus skips the whole object. At the top level
+// Skip does nothing.
+//
+// Skipped Bytes values are not decoded which makes skipping images and other
+// binary data fast.
+func (d *Decoder) Skip() error {
+	if d.err != nil {
+		return d.err
+	}
+	if d.depth == 0 && !d.inValue {
+		return nil
+	}
+	target := d.depth - 1
+	if d.inValue {
+		target = d.depth
+	}
+	for d.depth > target || d.inValue {
+		if d.inValue {
+			d.inValue = false
+			d.p.skipValue()
+			if d.p.err != nil {
+				d.err = d.p.err
+				return d.err
+			}
+		} else if _, err := d.Token(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) init() {
+	// The encoding is detected from the first buffer full of code.
+	start, _ := d.r.Peek(d.r.Size())
+	if isBinary(start) {
+		d.err = errors.New("dfm.Decoder: binary DFMs cannot be decoded as a stream")
+		return
+	}
+	d.p = newParser(nil, ParseOptions{
+		FileName:    d.opts.FileName,
+		ExactFloats: d.opts.ExactFloats,
+		CodePage:    d.opts.CodePage,
+	})
+	enc := d.opts.Encoding
+	if enc == AutoEncoding {
+		enc = detectStreamEncoding(start)
+	}
+	bom := hasBOM(start, enc)
+	switch enc {
+	case UTF16LE, UTF16BE:
+		if bom {
+			d.r.Discard(len(utf16leBOM))
+		}
+		d.p.tokens.src = &utf16Reader{r: d.r, enc: enc}
+	case UTF8:
+		if bom {
+			d.r.Discard(len(utf8bom))
+			d.p.tokens.base = len(utf8bom)
+		}
+		d.p.tokens.src = d.r
+	case ANSI:
+		d.p.tokens.src = d.r
+		d.p.tokens.cp, d.err = lookupCodePage(d.opts.CodePage)
+	default:
+		d.err = fmt.Errorf("dfm.Decoder: unknown encoding %v", enc)
+	}
+}
+
+func (d *Decoder) token() (Token, error) {
+	p := d.p
+	if d.inValue {
+		d.inValue = false
+		v := p.parseValue()
+		return Value{Value: v}, p.err
+	}
+	if d.depth == 0 && p.peekEOF() {
+		return nil, io.EOF
+	}
+	if d.depth > 0 && (p.peekEOF() || p.peekWord("end")) {
+		p.nextToken()
+		d.depth--
+		return ObjectEnd{}, p.err
+	}
+	if d.depth == 0 || p.peekObjectStart() {
+		var obj Object
+		p.parseObjectHeader(&obj)
+		d.depth++
+		return ObjectStart{
+			Name:     obj.Name,
+			Type:     obj.Type,
+			Kind:     obj.Kind,
+			HasIndex: obj.HasIndex,
+			Index:    obj.Index,
+		}, p.err
+	}
+	name := p.parsePropertyName()
+	p.token('=')
+	d.inValue = true
+	return PropertyName(name), p.err
+}
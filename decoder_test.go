@@ -0,0 +1,224 @@
+package dfm_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func decodeAll(t *testing.T, r io.Reader) []dfm.Token {
+	t.Helper()
+	var tokens []dfm.Token
+	d := dfm.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return tokens
+		}
+		check.Eq(t, err, nil)
+		if err != nil {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
+}
+
+func TestDecoderReturnsTokensInOrder(t *testing.T) {
+	tokens := decodeAll(t, iotest.OneByteReader(strings.NewReader(`object Form1: TForm1
+  Font.Height = -11
+  Anchors = [akLeft, akTop]
+  inherited Panel1: TPanel [2]
+    Caption = 'Hello ' +
+      'World'
+    object TMenuItem
+    end
+  end
+  Data = {0102}
+end
+object Second: TForm
+end`)))
+	check.Eq(t, tokens, []dfm.Token{
+		dfm.ObjectStart{Name: "Form1", Type: "TForm1"},
+		dfm.PropertyName("Font.Height"),
+		dfm.Value{Value: dfm.Int(-11)},
+		dfm.PropertyName("Anchors"),
+		dfm.Value{Value: dfm.Set{dfm.Identifier("akLeft"), dfm.Identifier("akTop")}},
+		dfm.ObjectStart{Name: "Panel1", Type: "TPanel", Kind: dfm.Inherited, HasIndex: true, Index: 2},
+		dfm.PropertyName("Caption"),
+		dfm.Value{Value: dfm.String("Hello World")},
+		dfm.ObjectStart{Type: "TMenuItem"},
+		dfm.ObjectEnd{},
+		dfm.ObjectEnd{},
+		dfm.PropertyName("Data"),
+		dfm.Value{Value: dfm.Bytes{1, 2}},
+		dfm.ObjectEnd{},
+		dfm.ObjectStart{Name: "Second", Type: "TForm"},
+		dfm.ObjectEnd{},
+	})
+}
+
+func TestDecoderSkipsObjectsAndValues(t *testing.T) {
+	d := dfm.NewDecoder(strings.NewReader(`object Form1: TForm1
+  object Image1: TImage
+    Picture.Data = {0102}
+    object Child: TChild
+    end
+  end
+  Picture.Data = {
+    0304}
+  Caption = 'Form'
+end`))
+	var tokens []dfm.Token
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		check.Eq(t, err, nil)
+		tokens = append(tokens, tok)
+		if start, ok := tok.(dfm.ObjectStart); ok && start.Type == "TImage" {
+			check.Eq(t, d.Skip(), nil)
+		}
+		if tok == dfm.PropertyName("Picture.Data") {
+			check.Eq(t, d.Skip(), nil)
+		}
+	}
+	check.Eq(t, tokens, []dfm.Token{
+		dfm.ObjectStart{Name: "Form1", Type: "TForm1"},
+		dfm.ObjectStart{Name: "Image1", Type: "TImage"},
+		dfm.PropertyName("Picture.Data"),
+		dfm.PropertyName("Caption"),
+		dfm.Value{Value: dfm.String("Form")},
+		dfm.ObjectEnd{},
+	})
+}
+
+func TestDecoderSkipInsideObjectSkipsRestOfObject(t *testing.T) {
+	d := dfm.NewDecoder(strings.NewReader(`object A: TA
+  object B: TB
+    X = 1
+    Y = 2
+  end
+  Z = 3
+end`))
+	for i := 0; i < 4; i++ {
+		_, err := d.Token()
+		check.Eq(t, err, nil)
+	}
+	// We are behind B.X = 1 now.
+	check.Eq(t, d.Skip(), nil)
+	tok, err := d.Token()
+	check.Eq(t, err, nil)
+	check.Eq(t, tok, dfm.PropertyName("Z"))
+}
+
+func TestDecoderHandlesValuesLargerThanItsBuffer(t *testing.T) {
+	hex := strings.Repeat("0123456789ABCDEF", 1000)
+	tokens := decodeAll(t, strings.NewReader(
+		"object A: TA\r\n  Data = {\r\n"+hex+"\r\n"+hex+"}\r\n  Name = '"+
+			strings.Repeat("x", 10000)+"'\r\nend"))
+	check.Eq(t, len(tokens), 6)
+	check.Eq(t, len(tokens[2].(dfm.Value).Value.(dfm.Bytes)), 16000)
+	check.Eq(t, tokens[4], dfm.Value{Value: dfm.String(strings.Repeat("x", 10000))})
+}
+
+func TestDecoderDecodesUTF8WithBOMAndANSIWithout(t *testing.T) {
+	tokens := decodeAll(t, strings.NewReader("\xEF\xBB\xBFobject A: TA\nS = 'ä'\nend"))
+	check.Eq(t, tokens[2], dfm.Value{Value: dfm.String("ä")})
+
+	// Runes might be split across reads.
+	tokens = decodeAll(t, iotest.OneByteReader(
+		strings.NewReader("\xEF\xBB\xBFobject A: TA\nS = '日本'\nend")))
+	check.Eq(t, tokens[2], dfm.Value{Value: dfm.String("日本")})
+
+	tokens = decodeAll(t, strings.NewReader("object A: TA\nS = '\xE4'\nend"))
+	check.Eq(t, tokens[2], dfm.Value{Value: dfm.String("ä")})
+}
+
+func TestDecoderReturnsSyntaxErrors(t *testing.T) {
+	d := dfm.NewDecoderWithOptions(
+		strings.NewReader("object A: TA\n  X 1\nend"),
+		dfm.ParseOptions{FileName: "a.dfm"},
+	)
+	d.Token()
+	_, err := d.Token()
+	check.Eq(t, err.Error(), `a.dfm:2:5: '=' expected but was "1"`)
+	_, err2 := d.Token()
+	check.Eq(t, err2, err)
+}
+
+func TestDecoderReturnsReadErrors(t *testing.T) {
+	readErr := errors.New("read failed")
+	d := dfm.NewDecoder(io.MultiReader(
+		strings.NewReader("object A: TA\n  X = 1\n"),
+		&failingReader{err: readErr},
+	))
+	var err error
+	for err == nil {
+		_, err = d.Token()
+	}
+	check.Eq(t, err, readErr)
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func TestDecoderRejectsBinaryDFMs(t *testing.T) {
+	_, err := dfm.NewDecoder(strings.NewReader("TPF0\x06TPanel")).Token()
+	check.Neq(t, err, nil)
+}
+
+func TestDecoderDecodesUTF16(t *testing.T) {
+	code := "object A: TA\r\n  S = '日本😀'\r\nend\r\n"
+	for _, bigEndian := range []bool{false, true} {
+		// Characters might be split across reads.
+		tokens := decodeAll(t, iotest.OneByteReader(bytes.NewReader(toUTF16(code, bigEndian))))
+		check.Eq(t, len(tokens), 4, bigEndian)
+		check.Eq(t, tokens[2], dfm.Value{Value: dfm.String("日本😀")}, bigEndian)
+	}
+}
+
+func TestDecoderDetectsUTF8WithoutBOM(t *testing.T) {
+	tokens := decodeAll(t, iotest.OneByteReader(strings.NewReader("object A: TA\nS = 'ä'\nend")))
+	check.Eq(t, tokens[2], dfm.Value{Value: dfm.String("ä")})
+
+	d := dfm.NewDecoderWithOptions(
+		strings.NewReader("object A: TA\nS = 'ä'\nend"),
+		dfm.ParseOptions{Encoding: dfm.ANSI},
+	)
+	var last dfm.Token
+	for i := 0; i < 3; i++ {
+		last, _ = d.Token()
+	}
+	check.Eq(t, last, dfm.Value{Value: dfm.String("Ã¤")})
+}
+
+func TestDecoderIgnoresParents(t *testing.T) {
+	var parents dfm.Parents
+	d := dfm.NewDecoderWithOptions(
+		strings.NewReader("object A: TA\n  object B: TB\n  end\nend"),
+		dfm.ParseOptions{Parents: &parents},
+	)
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		check.Eq(t, err, nil)
+		if err != nil {
+			break
+		}
+	}
+	check.Eq(t, len(parents), 0)
+}
@@ -1,49 +1,217 @@
 package dfm
 
 import (
-	"bytes"
-	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 )
 
 // ParseReader parses one object read from the given io.Reader. See ParseBytes.
 func ParseReader(r io.Reader) (*Object, error) {
+	return ParseReaderWithOptions(r, ParseOptions{})
+}
+
+// ParseFile parses one object read from the given file. See ParseBytes.
+func ParseFile(path string) (*Object, error) {
+	return ParseFileWithOptions(path, ParseOptions{})
+}
+
+// ParseBytes expects the code to start with an object. The first object in the
+// given code is parsed, if there are more, they are ignored (see
+// ParseOptions.Strict and ParseAll). A DFM file typically has one top-level
+// object defined in it. It might contain child objects however. The code may
+// start with a UTF-8 byte oder mark (0xEF,0xBB,0xBF). Code that starts with a
+// UTF-16 byte order mark (0xFF,0xFE for little endian or 0xFE,0xFF for big
+// endian) is decoded as UTF-16. Code without byte order mark is decoded as
+// UTF-8 if it is valid UTF-8, otherwise as Windows-1252, see DetectEncoding and
+// ParseOptions.Encoding.
+//
+// Binary DFM files, as well as form resources extracted from executables, are
+// detected by their leading resource header (0xFF) or their "TPF0" signature
+// and are decoded into the same Object tree.
+func ParseBytes(code []byte) (*Object, error) {
+	return ParseBytesWithOptions(code, ParseOptions{})
+}
+
+// ParseString parses one object read from the given file. See ParseBytes. The
+// code must not start with a UTF-8 byte oder mark.
+func ParseString(code string) (*Object, error) {
+	return ParseStringWithOptions(code, ParseOptions{})
+}
+
+// ParseOptions enable optional features of the parser. The zero value is the
+// default used by ParseBytes and its siblings.
+type ParseOptions struct {
+	// FileName is used in ParseErrors. ParseFile and ParseFileWithOptions
+	// set it to the file path if it is empty.
+	FileName string
+	// Positions is filled with the locations of all parsed objects and
+	// properties if it is not nil. Recording positions costs time and memory
+	// so leave this nil if you do not need them.
+	Positions *Positions
+	// Recover makes the parser continue after syntax errors. Instead of
+	// stopping at the first error, the parser skips to the next point where
+	// it can continue, e.g. the next line that starts with a property name or
+	// the next "end" keyword. Properties that could not be parsed get a
+	// BadValue. The Parse functions then return the best-effort Object tree
+	// and an ErrorList with all errors, or nil if there are none. The Object
+	// is nil only if no object could be found at all.
+	Recover bool
+	// Lossless makes the parsed Objects remember their original code. When
+	// printed, objects and properties that were not changed are written
+	// exactly as they were in the code, including white space, the spelling
+	// of numbers and the way strings were split and escaped. Only changed,
+	// added or removed properties change the output. Lossless parsing costs
+	// memory since all values are copied to detect changes later.
+	Lossless bool
+	// Strict makes it an error if there is any code other than white space after
+	// the top-level object. By default that code is ignored. Use ParseAll to
+	// parse all objects in the code.
+	Strict bool
+	// ExactFloats makes the parser return floating point numbers without a
+	// suffix as Extended instead of Float. Extended keeps the exact 80 bit
+	// value that Delphi uses, so no digits are lost when printing the value
+	// again or writing it to a binary DFM.
+	ExactFloats bool
+	// FileInfo is filled with the encoding, byte order mark and line breaks of
+	// text DFMs if it is not nil.
+	FileInfo *FileInfo
+	// Parents is filled with the parent of every child object if it is not
+	// nil, see Parents. The Decoder ignores it.
+	Parents *Parents
+	// Encoding of text DFMs. By default it is detected, see DetectEncoding.
+	// Set it to override the detection. A byte order mark is skipped if it
+	// matches the encoding.
+	Encoding Encoding
+	// CodePage is the Windows code page of code in the ANSI encoding and of
+	// ANSI strings in binary DFMs. The default 0 means Windows-1252. Supported
+	// are the single byte code pages 874 (Thai) and 1250 to 1258 and the
+	// double byte code pages 932 (Japanese), 936 (Simplified Chinese), 949
+	// (Korean) and 950 (Traditional Chinese).
+	CodePage int
+}
+
+// ParseReaderWithOptions is like ParseReader but uses the given options.
+func ParseReaderWithOptions(r io.Reader, opts ParseOptions) (*Object, error) {
 	code, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	return ParseBytes(code)
+	return ParseBytesWithOptions(code, opts)
 }
 
-// ParseFile parses one object read from the given file. See ParseBytes.
-func ParseFile(path string) (*Object, error) {
+// ParseFileWithOptions is like ParseFile but uses the given options.
+func ParseFileWithOptions(path string, opts ParseOptions) (*Object, error) {
 	code, err := ioutil.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	return ParseBytes(code)
+	if opts.FileName == "" {
+		opts.FileName = path
+	}
+	return ParseBytesWithOptions(code, opts)
 }
 
-// ParseBytes expects the code to start with an object. The first object in the
-// given code is parsed, if there are more, they are ignored. A DFM file
-// typically has one top-level object defined in it. It might contain child
-// objects however. The code is expected to be UTF-8 encoded. It may start with
-// a UTF-8 byte oder mark (0xEF,0xBB,0xBF).
-func ParseBytes(code []byte) (*Object, error) {
-	if len(code) > 0 && code[0] == 0xFF {
-		return nil, errors.New("dfm.Parse: binary DFM files are not supported")
-	} else if bytes.HasPrefix(code, utf8bom) || allASCII(code) {
-		return parse(bytes.Runes(bytes.TrimPrefix(code, utf8bom)))
-	} else {
-		return parse(decodeWindowsANSI(code))
+// ParseBytesWithOptions is like ParseBytes but uses the given options.
+func ParseBytesWithOptions(code []byte, opts ParseOptions) (*Object, error) {
+	if isBinary(code) {
+		return parseBinary(code, opts)
+	}
+	p, hasBOM, err := newTextParser(code, opts)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := p.parse()
+	if obj != nil && obj.source != nil {
+		obj.source.bom = hasBOM
 	}
+	return obj, err
 }
 
-// ParseString parses one object read from the given file. See ParseBytes. The
-// code must not start with a UTF-8 byte oder mark.
-func ParseString(code string) (*Object, error) {
-	return parse([]rune(code))
+// newTextParser creates a parser for the given text DFM code in the
+// ParseOptions.Encoding or, by default, the detected encoding. ANSI code is
+// decoded in ParseOptions.CodePage. UTF-16 code is converted to UTF-8 first,
+// its Position offsets are offsets into the UTF-8 code, without byte order
+// mark.
+func newTextParser(code []byte, opts ParseOptions) (p *parser, bom bool, err error) {
+	enc := opts.Encoding
+	if enc == AutoEncoding {
+		enc = DetectEncoding(code)
+	}
+	bom = hasBOM(code, enc)
+	switch enc {
+	case UTF16LE, UTF16BE:
+		if bom {
+			code = code[len(utf16leBOM):]
+		}
+		p = newParser(decodeUTF16(code, enc), opts)
+	case UTF8:
+		if bom {
+			p = newParser(code[len(utf8bom):], opts)
+			p.tokens.base = len(utf8bom)
+		} else {
+			p = newParser(code, opts)
+		}
+	case ANSI:
+		p = newParser(code, opts)
+		if !allASCII(code) {
+			p.tokens.cp, err = lookupCodePage(opts.CodePage)
+		}
+	default:
+		return nil, false, fmt.Errorf("dfm: unknown encoding %v", enc)
+	}
+	if opts.FileInfo != nil {
+		*opts.FileInfo = newFileInfo(p.tokens.code, enc, opts.CodePage, bom)
+	}
+	return p, bom, err
+}
+
+// ParseAll parses all top-level objects in the given code. Use it for files
+// that define more than one object and for code copied from the Delphi form
+// designer, which puts all selected components one after another. The code is
+// decoded like in ParseBytes. Binary DFMs always contain a single object.
+// Empty code, or code with only white space, results in an empty
+// list.
+func ParseAll(code []byte) ([]*Object, error) {
+	return ParseAllWithOptions(code, ParseOptions{})
+}
+
+// ParseAllWithOptions is like ParseAll but uses the given options.
+// ParseOptions.Strict has no effect since all the code must be objects
+// anyway.
+func ParseAllWithOptions(code []byte, opts ParseOptions) ([]*Object, error) {
+	if isBinary(code) {
+		obj, err := parseBinary(code, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []*Object{obj}, nil
+	}
+	p, hasBOM, err := newTextParser(code, opts)
+	if err != nil {
+		return nil, err
+	}
+	objs, err := p.parseAll()
+	if len(objs) > 0 && objs[0].source != nil {
+		objs[0].source.bom = hasBOM
+	}
+	return objs, err
+}
+
+// ParseFragment parses a clipboard fragment, e.g. the text that Delphi puts on
+// the clipboard when copying components in the form designer. It is like
+// ParseAll for a string.
+func ParseFragment(code string) ([]*Object, error) {
+	return newParser([]byte(code), ParseOptions{}).parseAll()
+}
+
+// ParseStringWithOptions is like ParseString but uses the given options.
+// Position offsets are byte offsets into the string.
+func ParseStringWithOptions(code string, opts ParseOptions) (*Object, error) {
+	if opts.FileInfo != nil {
+		*opts.FileInfo = newFileInfo([]byte(code), UTF8, 0, false)
+	}
+	return parse([]byte(code), opts)
 }
 
 // Object can be a TPanel, TLabel, TForm, a sub-class of these or any other
@@ -69,6 +237,8 @@ type Object struct {
 	HasIndex   bool
 	Index      int
 	Properties []Property
+	// source is only set for Objects parsed with ParseOptions.Lossless.
+	source *objectSource
 }
 
 // ObjectKind represents the keyword used to define an object in the DFM.
@@ -94,9 +264,10 @@ func (k ObjectKind) String() string {
 	}
 }
 
-// Property is what is contained in an Object. Possible types are Int, Float,
-// Bool, String, Identifier, Set, Tuple, Bytes, Items and Object. Except for
-// Object, these will appear in the DFM file as:
+// Property is what is contained in an Object. Possible types are Int, Int64,
+// UInt64, Float, Extended, Single, Currency, Date, Bool, String, Identifier,
+// Set, Tuple, Bytes, Items and Object. Except for Object, these will appear in
+// the DFM file as:
 //
 //     <name> = <value>
 //
@@ -112,13 +283,48 @@ type PropertyValue interface {
 	isPropertyValue()
 }
 
-// Int is a base 10 integer literal.
-type Int int
+// Int is a base 10 integer literal. It can hold all signed 64 bit values. In
+// binary DFMs it is written in the smallest possible encoding, 8, 16, 32 or 64
+// bits, like Delphi does.
+type Int int64
+
+// Int64 is an integer that is always written as a 64 bit value in binary DFMs.
+// Delphi writes small values in fewer bits, so Int64 only appears when reading
+// binary DFMs that store a value in 64 bits that would fit into 32 bits. Keeping
+// it apart from Int preserves the encoding when writing the DFM back. In text
+// DFMs it looks just like an Int.
+type Int64 int64
+
+// UInt64 is an integer above the range of Int, up to the maximum unsigned 64
+// bit value. Delphi has no unsigned value type in DFMs, UInt64 properties are
+// stored as 64 bit integers with the same bits. Text DFMs may contain these
+// values in unsigned form, which the parser returns as UInt64.
+type UInt64 uint64
 
 // Float is a floating point number, e.g. 1.23 or 4.5E-6. Float values of NaN
 // and +-Infinity will be printed as 0 since DFMs do not allow them.
 type Float float64
 
+// Single is a 32 bit floating point number. In DFM code it has an 's' suffix,
+// e.g. 1.5s. Delphi writes properties of type Single like this.
+type Single float32
+
+// Currency is a fixed point number with four decimal places. Like in Delphi,
+// the value is stored multiplied by 10000, e.g. Currency(123400) is 12.34. In
+// DFM code it has a 'c' suffix and is written multiplied by 10000 as well, so
+// 12.34 appears as 123400c.
+type Currency int64
+
+// Float64 returns the actual value of c, e.g. 12.34 for Currency(123400).
+func (c Currency) Float64() float64 {
+	return float64(c) / 10000
+}
+
+// Date is a Delphi TDateTime. In DFM code it has a 'd' suffix, e.g. 45000.5d.
+// The integer part is the number of days since 1899-12-30, the fractional part
+// is the time of day.
+type Date float64
+
 // Bool is either True or False.
 type Bool bool
 
@@ -163,9 +369,21 @@ type Items [][]Property
 //     { FFAC2938AA991234A }
 type Bytes []byte
 
+// BadValue marks a property that could not be parsed because of a syntax error.
+// It only appears in Objects parsed with ParseOptions.Recover. It contains the
+// code of the whole damaged property, starting with its name, up to the point
+// where the parser resumed. The printer writes this code as is.
+type BadValue string
+
 func (*Object) isPropertyValue()    {}
 func (Int) isPropertyValue()        {}
+func (Int64) isPropertyValue()      {}
+func (UInt64) isPropertyValue()     {}
 func (Float) isPropertyValue()      {}
+func (Extended) isPropertyValue()   {}
+func (Single) isPropertyValue()     {}
+func (Currency) isPropertyValue()   {}
+func (Date) isPropertyValue()       {}
 func (Bool) isPropertyValue()       {}
 func (String) isPropertyValue()     {}
 func (Identifier) isPropertyValue() {}
@@ -173,3 +391,4 @@ func (Set) isPropertyValue()        {}
 func (Tuple) isPropertyValue()      {}
 func (Items) isPropertyValue()      {}
 func (Bytes) isPropertyValue()      {}
+func (BadValue) isPropertyValue()   {}
@@ -8,13 +8,28 @@ Use any of these functions to parse a DFM file:
 	ParseFile(path string)
 	ParseReader(r io.Reader)
 
-They all return a dfm.Object and error.
+They all return a dfm.Object and error. Both text and binary DFM files are
+supported. Each of these functions has a ...WithOptions variant which takes
+ParseOptions, e.g. to record the source Positions of all objects and properties.
+
+Code with multiple top-level objects, e.g. components copied from the Delphi
+form designer, can be parsed with ParseAll or ParseFragment and printed with
+PrintAll.
+
+To scan large files without building the whole tree in memory, read them token
+by token with a Decoder, see NewDecoder.
 
 A DFM file contains one root Object which contains other objects and properties,
 forming a tree structure. Properties can be of types (see file dfm.go):
 
 	Int
+	Int64
+	UInt64
 	Float
+	Extended
+	Single
+	Currency
+	Date
 	Bool
 	String
 	Identifier
@@ -25,6 +40,42 @@ forming a tree structure. Properties can be of types (see file dfm.go):
 
 You can maipulate the in-memory tree by replacing its nodes.
 
+To navigate the tree, use Object.Children, Child, FindObject, Property and
+ParentOf. Get takes a dotted path of child object names and a property name:
+
+	height, ok := form.Get("Panel3.Button1.Font.Height")
+
+Objects do not know their parents. Set ParseOptions.Parents to have the parent
+of every object recorded while parsing, or call Object.Parents to build the map
+for an existing tree.
+
+Typed getters like GetInt, GetString or GetStrings save the type assertions,
+setters like SetInt or SetString change existing properties in place or add
+new ones. Remove deletes properties and child objects.
+
+Walk and Inspect traverse all objects, properties, collection items and values
+in Sets and Tuples. A Cursor tells the path of each node and can replace it:
+
+	dfm.Inspect(form, func(c *dfm.Cursor) bool {
+		if c != nil && c.Value() == dfm.Identifier("clRed") {
+			c.Replace(dfm.Identifier("clMaroon"))
+		}
+		return true
+	})
+
+Query finds objects and properties with CSS-like selectors, e.g. all TDBEdits
+on TTabSheets that have no DataField:
+
+	matches, err := form.Query("TTabSheet TDBEdit[DataField='']")
+
+See ParseSelector for the syntax. The command line tool
+github.com/gonutz/dfm/cmd/dfmquery runs such queries on DFM files.
+
+Clone makes a deep copy of an Object. Equal compares two trees, numbers of
+different types are equal if they have the same value. EqualWithOptions can
+ignore the order of properties, the case of names, listed properties and small
+differences between floating point numbers.
+
 To write an Object to a file, use any of these functions:
 
 	Object.Print() []byte
@@ -33,5 +84,23 @@ To write an Object to a file, use any of these functions:
 
 These will create an ASCII or UTF-8 encoded (depending on whether the DFM
 contains unicode characters in its identifiers) code file, readable by Delphi.
+To choose the encoding, e.g. UTF-16, the byte order mark or the line breaks,
+use Object.WriteToWithOptions with PrintOptions. To keep the ones of a parsed
+file, pass a FileInfo in ParseOptions and print with FileInfo.PrintOptions.
+
+To change a few properties in an existing DFM without re-printing the whole
+file, use an Editor. It applies minimal text edits to the original code:
+
+	e, err := dfm.NewEditor(code)
+	e.SetProperty("Panel1.Button1.Caption", dfm.String("OK"))
+	code = e.Apply()
+
+For ANSI files in a code page other than Windows-1252, use NewEditorWithOptions
+with ParseOptions.CodePage.
+
+To write an Object in Delphi's binary format, use one of these:
+
+	Object.WriteBinaryTo(w io.Writer) error
+	Object.WriteBinaryResourceTo(w io.Writer) error
 */
 package dfm
@@ -0,0 +1,372 @@
+package dfm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Editor changes DFM code in place. Instead of printing the whole Object tree,
+// it records the minimal text edits against the original code, everything that
+// is not edited stays byte for byte the same. Use it to change a few properties
+// in files that the printer would not reproduce exactly.
+//
+// Paths name child objects and properties relative to the top-level object,
+// separated by dots. Leading path parts that match child object names descend
+// into these objects, the rest of the path is the property name, which might
+// contain dots itself. For example "Panel1.Button1.Font.Height" is the property
+// Font.Height of Button1, which is a child of Panel1, which is a child of the
+// top-level object. Names are compared case-insensitively, like Delphi does.
+type Editor struct {
+	code      []byte
+	root      *Object
+	positions Positions
+	newline   string
+	// cp is the code page of ANSI code, nil for UTF-8.
+	cp     *codePage
+	hasBOM bool
+
+	edits []TextEdit
+	// replaced maps properties to the index of the edit that replaces their
+	// value or deletes them.
+	replaced map[*Property]int
+	deleted  map[*Property]bool
+	// inserted maps new properties, identified by their object and lower-case
+	// name, to the index of the edit that inserts them.
+	inserted map[insertedProperty]int
+}
+
+type insertedProperty struct {
+	obj  *Object
+	name string
+}
+
+// TextEdit replaces the bytes Start to End (exclusive) of the original code
+// with Text. For insertions Start and End are equal.
+type TextEdit struct {
+	Start, End int
+	Text       []byte
+}
+
+// NewEditor parses the given text DFM code for editing. Binary DFMs and UTF-16
+// code cannot be edited.
+func NewEditor(code []byte) (*Editor, error) {
+	return NewEditorWithOptions(code, ParseOptions{})
+}
+
+// NewEditorWithOptions is like NewEditor but parses the code with the given
+// options. ANSI code is decoded and new text is encoded in opts.CodePage. The
+// Editor records its own positions, opts.Positions, Recover and Lossless are
+// ignored.
+func NewEditorWithOptions(code []byte, opts ParseOptions) (*Editor, error) {
+	if isBinary(code) {
+		return nil, errors.New("dfm.NewEditor: binary DFMs cannot be edited")
+	}
+	enc := opts.Encoding
+	if enc == AutoEncoding {
+		enc = DetectEncoding(code)
+	}
+	if _, ok := utf16BOM(code); ok || enc == UTF16LE || enc == UTF16BE {
+		return nil, errors.New("dfm.NewEditor: UTF-16 DFMs cannot be edited")
+	}
+	e := &Editor{
+		code:     code,
+		newline:  "\n",
+		replaced: make(map[*Property]int),
+		deleted:  make(map[*Property]bool),
+		inserted: make(map[insertedProperty]int),
+	}
+	if bytes.Contains(code, []byte("\r\n")) {
+		e.newline = "\r\n"
+	}
+	e.hasBOM = bytes.HasPrefix(code, utf8bom)
+	if enc == ANSI && !allASCII(code) {
+		cp, err := lookupCodePage(opts.CodePage)
+		if err != nil {
+			return nil, err
+		}
+		e.cp = cp
+	}
+
+	opts.Positions = &e.positions
+	opts.Recover = false
+	opts.Lossless = false
+	root, err := ParseBytesWithOptions(code, opts)
+	if err != nil {
+		return nil, err
+	}
+	e.root = root
+	return e, nil
+}
+
+// Object returns the Object tree of the original code. It does not reflect the
+// edits and must not be modified.
+func (e *Editor) Object() *Object {
+	return e.root
+}
+
+// SetProperty sets the value of the property at the given path. If the
+// property exists, only its value is replaced. Otherwise a new property line is
+// inserted after the last existing property of the object, before its child
+// objects.
+func (e *Editor) SetProperty(path string, value PropertyValue) error {
+	if _, ok := value.(*Object); ok {
+		return errors.New("dfm.Editor.SetProperty: use InsertObject to add objects")
+	}
+	_, obj, name := lookup(e.root, path)
+	if name == "" {
+		return fmt.Errorf("dfm.Editor.SetProperty: %q is an object, not a property", path)
+	}
+	if e.objectDeleted(obj) {
+		return fmt.Errorf("dfm.Editor.SetProperty: the object of %q was deleted", path)
+	}
+
+	if prop := findProperty(obj, name); prop != nil {
+		if e.deleted[prop] {
+			return fmt.Errorf("dfm.Editor.SetProperty: %q was deleted", path)
+		}
+		pos := e.positions.Properties[prop]
+		text, err := e.valueText(value, pos.Name.Start)
+		if err != nil {
+			return err
+		}
+		edit := TextEdit{Start: pos.Value.Start.Offset, End: pos.Value.End.Offset, Text: text}
+		if i, ok := e.replaced[prop]; ok {
+			e.edits[i] = edit
+		} else {
+			e.replaced[prop] = len(e.edits)
+			e.edits = append(e.edits, edit)
+		}
+		return nil
+	}
+
+	objPos := e.positions.Objects[obj]
+	indent := e.indentation(objPos.Header.Start) + "  "
+	p := newPrinter(PrintOptions{})
+	p.indent = indent
+	p.property(Property{Name: name, Value: value})
+	text, err := e.encode(p.String())
+	if err != nil {
+		return err
+	}
+
+	at := e.lineEnd(objPos.Header.End.Offset)
+	for i := range obj.Properties {
+		if _, isObject := obj.Properties[i].Value.(*Object); !isObject {
+			at = e.lineEnd(e.positions.Properties[&obj.Properties[i]].Value.End.Offset)
+		}
+	}
+	if at > 0 && e.code[at-1] != '\n' {
+		// Other code follows on the same line, e.g. in "object A: TA end".
+		text = append([]byte(e.newline), text...)
+	}
+
+	key := insertedProperty{obj: obj, name: strings.ToLower(name)}
+	if i, ok := e.inserted[key]; ok {
+		e.edits[i].Text = text
+		return nil
+	}
+	e.inserted[key] = len(e.edits)
+	e.edits = append(e.edits, TextEdit{Start: at, End: at, Text: text})
+	return nil
+}
+
+// DeleteProperty removes the property or child object at the given path. The
+// lines that contained it are removed completely.
+func (e *Editor) DeleteProperty(path string) error {
+	parent, obj, name := lookup(e.root, path)
+	var prop *Property
+	if name == "" {
+		if parent == nil {
+			return errors.New("dfm.Editor.DeleteProperty: cannot delete the top-level object")
+		}
+		for i := range parent.Properties {
+			if parent.Properties[i].Value == obj {
+				prop = &parent.Properties[i]
+			}
+		}
+	} else {
+		prop = findProperty(obj, name)
+	}
+	if prop == nil {
+		return fmt.Errorf("dfm.Editor.DeleteProperty: %q not found", path)
+	}
+	if e.deleted[prop] || e.objectDeleted(obj) {
+		return nil
+	}
+
+	pos := e.positions.Properties[prop]
+	start, end := pos.Name.Start.Offset, pos.Value.End.Offset
+	if pos.Value.Start.Offset < start {
+		// Objects start with their keyword, not with their name.
+		start = pos.Value.Start.Offset
+	}
+	lineStart := e.lineStart(start)
+	if len(bytes.TrimLeft(e.code[lineStart:start], " \t")) == 0 && e.lineEnd(end) > end {
+		start, end = lineStart, e.lineEnd(end)
+	}
+
+	// Edits inside the deleted code are obsolete now, we mark them as empty
+	// insertions so the indices in e.replaced and e.inserted stay valid.
+	for i := range e.edits {
+		if start <= e.edits[i].Start && e.edits[i].End <= end {
+			e.edits[i] = TextEdit{Start: start, End: start}
+		}
+	}
+	e.deleted[prop] = true
+	e.replaced[prop] = len(e.edits)
+	e.edits = append(e.edits, TextEdit{Start: start, End: end})
+	return nil
+}
+
+// InsertObject adds the given Object as the last child of the object at the
+// given path. Use an empty path to insert into the top-level object.
+func (e *Editor) InsertObject(path string, child *Object) error {
+	_, obj, name := lookup(e.root, path)
+	if name != "" {
+		return fmt.Errorf("dfm.Editor.InsertObject: object %q not found", path)
+	}
+	if e.objectDeleted(obj) {
+		return fmt.Errorf("dfm.Editor.InsertObject: object %q was deleted", path)
+	}
+	objPos := e.positions.Objects[obj]
+	p := newPrinter(PrintOptions{})
+	p.indent = e.indentation(objPos.Header.Start) + p.opts.Indent
+	p.object(child)
+	text, err := e.encode(p.String())
+	if err != nil {
+		return err
+	}
+	at := e.lineStart(objPos.End.Start.Offset)
+	if len(bytes.TrimLeft(e.code[at:objPos.End.Start.Offset], " \t")) > 0 {
+		// The "end" is on the same line as other code.
+		at = objPos.End.Start.Offset
+		text = append([]byte(e.newline), text...)
+	}
+	e.edits = append(e.edits, TextEdit{Start: at, End: at, Text: text})
+	return nil
+}
+
+// Edits returns all text edits sorted by their position in the original code.
+// Insertions at the same position are kept in the order they were made. Edits
+// that overlap an earlier edit are left out.
+func (e *Editor) Edits() []TextEdit {
+	var all []TextEdit
+	for _, edit := range e.edits {
+		if edit.Start != edit.End || len(edit.Text) > 0 {
+			all = append(all, edit)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Start < all[j].Start
+	})
+	edits := make([]TextEdit, 0, len(all))
+	last := 0
+	for _, edit := range all {
+		if edit.Start >= last {
+			edits = append(edits, edit)
+			last = edit.End
+		}
+	}
+	return edits
+}
+
+// objectDeleted reports whether obj or one of its ancestors was deleted.
+func (e *Editor) objectDeleted(obj *Object) bool {
+	objects := e.root.pathTo(obj)
+	for i := 1; i < len(objects); i++ {
+		parent := objects[i-1]
+		for j := range parent.Properties {
+			if parent.Properties[j].Value == objects[i] && e.deleted[&parent.Properties[j]] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Apply returns the original code with all edits applied.
+func (e *Editor) Apply() []byte {
+	var buf bytes.Buffer
+	edits := e.Edits()
+	if e.needsBOM(edits) {
+		buf.Write(utf8bom)
+	}
+	last := 0
+	for _, edit := range edits {
+		buf.Write(e.code[last:edit.Start])
+		buf.Write(edit.Text)
+		last = edit.End
+	}
+	buf.Write(e.code[last:])
+	return buf.Bytes()
+}
+
+// needsBOM reports whether the edits introduce UTF-8 characters into a file
+// that was pure ASCII before.
+func (e *Editor) needsBOM(edits []TextEdit) bool {
+	if e.hasBOM || !allASCII(e.code) {
+		return false
+	}
+	for _, edit := range edits {
+		if !allASCII(edit.Text) {
+			return true
+		}
+	}
+	return false
+}
+
+// valueText prints the value as it would appear after the '=' of a property
+// whose name starts at the given position.
+func (e *Editor) valueText(value PropertyValue, name Position) ([]byte, error) {
+	p := newPrinter(PrintOptions{})
+	p.indent = e.indentation(name)
+	p.propertyValue(value)
+	return e.encode(p.String())
+}
+
+// encode converts printed text to the line breaks and encoding of the code.
+func (e *Editor) encode(text string) ([]byte, error) {
+	text = strings.Replace(text, "\r\n", e.newline, -1)
+	if e.cp == nil {
+		return []byte(text), nil
+	}
+	b, ok := e.cp.encode(text)
+	if !ok {
+		return nil, fmt.Errorf("dfm.Editor: %q cannot be encoded in %v", text, e.cp)
+	}
+	return b, nil
+}
+
+// indentation returns the white space at the start of the line of pos.
+func (e *Editor) indentation(pos Position) string {
+	start := e.lineStart(pos.Offset)
+	end := start
+	for end < len(e.code) && (e.code[end] == ' ' || e.code[end] == '\t') {
+		end++
+	}
+	return string(e.code[start:end])
+}
+
+// lineStart returns the offset of the start of the line containing offset.
+func (e *Editor) lineStart(offset int) int {
+	return bytes.LastIndexByte(e.code[:offset], '\n') + 1
+}
+
+// lineEnd returns the offset right after the line break following offset if
+// there is only white space between them. Otherwise offset is returned.
+func (e *Editor) lineEnd(offset int) int {
+	i := offset
+	for i < len(e.code) && (e.code[i] == ' ' || e.code[i] == '\t' || e.code[i] == '\r') {
+		i++
+	}
+	if i < len(e.code) && e.code[i] == '\n' {
+		return i + 1
+	}
+	if i == len(e.code) {
+		return i
+	}
+	return offset
+}
@@ -0,0 +1,166 @@
+package dfm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+const editorCode = "object Form1: TForm1\n" +
+	"  Left=0\n" +
+	"  Caption = 'abc' + 'def'\n" +
+	"  Scale = 1.5\n" +
+	"  object Panel1: TPanel\n" +
+	"    Font.Height = -11\n" +
+	"    object Button1: TButton\n" +
+	"      Caption = 'OK'\n" +
+	"    end\n" +
+	"  end\n" +
+	"end\n"
+
+func newEditor(t *testing.T, code string) *dfm.Editor {
+	t.Helper()
+	e, err := dfm.NewEditor([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestEditorReplacesOnlyTheValue(t *testing.T) {
+	e := newEditor(t, editorCode)
+	check.Eq(t, e.SetProperty("Caption", dfm.String("new")), nil)
+	check.Eq(t, e.SetProperty("panel1.BUTTON1.caption", dfm.String("Cancel")), nil)
+	check.Eq(t, e.Edits(), []dfm.TextEdit{
+		{Start: 42, End: 55, Text: []byte("'new'")},
+		{Start: 160, End: 164, Text: []byte("'Cancel'")},
+	})
+	want := strings.Replace(editorCode, "'abc' + 'def'", "'new'", 1)
+	want = strings.Replace(want, "'OK'", "'Cancel'", 1)
+	check.Eq(t, string(e.Apply()), want)
+}
+
+func TestEditorReplacesValueOnlyOnce(t *testing.T) {
+	e := newEditor(t, editorCode)
+	check.Eq(t, e.SetProperty("Left", dfm.Int(1)), nil)
+	check.Eq(t, e.SetProperty("Left", dfm.Int(2)), nil)
+	check.Eq(t, string(e.Apply()), strings.Replace(editorCode, "Left=0", "Left=2", 1))
+}
+
+func TestEditorInsertsNewPropertiesBeforeChildObjects(t *testing.T) {
+	e := newEditor(t, editorCode)
+	check.Eq(t, e.SetProperty("Top", dfm.Int(5)), nil)
+	check.Eq(t, e.SetProperty("Panel1.Button1.Font.Style", dfm.Set{dfm.Identifier("fsBold")}), nil)
+	check.Eq(t, e.SetProperty("Top", dfm.Int(6)), nil)
+	want := strings.Replace(editorCode, "  Scale = 1.5\n", "  Scale = 1.5\n  Top = 6\n", 1)
+	want = strings.Replace(want, "'OK'\n", "'OK'\n      Font.Style = [fsBold]\n", 1)
+	check.Eq(t, string(e.Apply()), want)
+}
+
+func TestEditorInsertsPropertiesIntoOneLineObjects(t *testing.T) {
+	e := newEditor(t, "object A: TA end")
+	check.Eq(t, e.SetProperty("Left", dfm.Int(5)), nil)
+	check.Eq(t, string(e.Apply()), "object A: TA\n  Left = 5\n end")
+	check.Eq(t, e.SetProperty("Left", dfm.Int(6)), nil)
+	check.Eq(t, string(e.Apply()), "object A: TA\n  Left = 6\n end")
+
+	e = newEditor(t, "object A: TA\r\n  Top = 1 end\r\n")
+	check.Eq(t, e.SetProperty("Left", dfm.Int(5)), nil)
+	check.Eq(t, string(e.Apply()), "object A: TA\r\n  Top = 1\r\n  Left = 5\r\n end\r\n")
+}
+
+func TestEditorDeletesWholeLines(t *testing.T) {
+	e := newEditor(t, editorCode)
+	check.Eq(t, e.SetProperty("Panel1.Button1.Caption", dfm.String("x")), nil)
+	check.Eq(t, e.DeleteProperty("Panel1.Button1"), nil)
+	check.Eq(t, e.DeleteProperty("Scale"), nil)
+	want := strings.Replace(editorCode, "  Scale = 1.5\n", "", 1)
+	want = strings.Replace(want, "    object Button1: TButton\n      Caption = 'OK'\n    end\n", "", 1)
+	check.Eq(t, string(e.Apply()), want)
+	check.Neq(t, e.SetProperty("Scale", dfm.Int(1)), nil)
+	check.Neq(t, e.DeleteProperty("Missing"), nil)
+}
+
+func TestEditorRejectsEditsInDeletedObjects(t *testing.T) {
+	e := newEditor(t, editorCode)
+	check.Eq(t, e.DeleteProperty("Panel1"), nil)
+	check.Neq(t, e.SetProperty("Panel1.Font.Height", dfm.Int(1)), nil)
+	check.Neq(t, e.SetProperty("Panel1.Width", dfm.Int(1)), nil)
+	check.Neq(t, e.SetProperty("Panel1.Button1.Caption", dfm.String("x")), nil)
+	check.Neq(t, e.InsertObject("Panel1", &dfm.Object{Type: "TLabel"}), nil)
+	check.Neq(t, e.InsertObject("Panel1.Button1", &dfm.Object{Type: "TLabel"}), nil)
+	// Deleting something that is already gone changes nothing.
+	check.Eq(t, e.DeleteProperty("Panel1.Button1.Caption"), nil)
+	want := strings.Replace(editorCode,
+		"  object Panel1: TPanel\n"+
+			"    Font.Height = -11\n"+
+			"    object Button1: TButton\n"+
+			"      Caption = 'OK'\n"+
+			"    end\n"+
+			"  end\n", "", 1)
+	check.Eq(t, string(e.Apply()), want)
+}
+
+func TestEditorSkipsOverlappingEdits(t *testing.T) {
+	e := newEditor(t, editorCode)
+	check.Eq(t, e.DeleteProperty("Panel1.Button1.Caption"), nil)
+	check.Eq(t, e.DeleteProperty("Panel1"), nil)
+	check.Eq(t, len(e.Edits()), 1)
+	check.Eq(t, strings.Contains(string(e.Apply()), "Panel1"), false)
+}
+
+func TestEditorInsertsObjects(t *testing.T) {
+	e := newEditor(t, strings.Replace(editorCode, "\n", "\r\n", -1))
+	check.Eq(t, e.InsertObject("Panel1", &dfm.Object{
+		Name: "Label1",
+		Type: "TLabel",
+		Properties: []dfm.Property{
+			{Name: "Caption", Value: dfm.String("Hi")},
+		},
+	}), nil)
+	want := strings.Replace(editorCode, "    end\n  end\n",
+		"    end\n    object Label1: TLabel\n      Caption = 'Hi'\n    end\n  end\n", 1)
+	want = strings.Replace(want, "\n", "\r\n", -1)
+	check.Eq(t, string(e.Apply()), want)
+}
+
+func TestEditorKeepsWindowsANSIEncoding(t *testing.T) {
+	e := newEditor(t, "object O: T\n  A = '\xE4'\nend\n")
+	check.Eq(t, e.SetProperty("B", dfm.Identifier("ö")), nil)
+	check.Eq(t, string(e.Apply()), "object O: T\n  A = '\xE4'\n  B = \xF6\nend\n")
+	check.Neq(t, e.SetProperty("C", dfm.Identifier("€€€日本")), nil)
+}
+
+func TestEditorUsesCodePageFromOptions(t *testing.T) {
+	// "При" in Windows-1251.
+	code := "object O: T\n  A = '\xCF\xF0\xE8'\nend\n"
+	e, err := dfm.NewEditorWithOptions([]byte(code), dfm.ParseOptions{CodePage: 1251})
+	check.Eq(t, err, nil)
+	check.Eq(t, e.Object().Properties[0].Value, dfm.String("При"))
+	check.Eq(t, e.SetProperty("B", dfm.Identifier("вет")), nil)
+	check.Eq(t, string(e.Apply()),
+		"object O: T\n  A = '\xCF\xF0\xE8'\n  B = \xE2\xE5\xF2\nend\n")
+	check.Neq(t, e.SetProperty("C", dfm.Identifier("ä")), nil)
+
+	_, err = dfm.NewEditorWithOptions([]byte(code), dfm.ParseOptions{CodePage: 12345})
+	check.Neq(t, err, nil)
+}
+
+func TestEditorAddsBOMForUnicode(t *testing.T) {
+	e := newEditor(t, "object O: T\nend\n")
+	check.Eq(t, e.SetProperty("B", dfm.Identifier("日本")), nil)
+	check.Eq(t, string(e.Apply()), string(utf8bom)+"object O: T\n  B = 日本\nend\n")
+}
+
+func TestEditorRejectsUTF16(t *testing.T) {
+	_, err := dfm.NewEditor(toUTF16("object O: T\nend\n", false))
+	check.Neq(t, err, nil)
+}
+
+func TestEditorKeepsUTF8WithoutBOM(t *testing.T) {
+	e := newEditor(t, "object O: T\n  A = 'ä'\nend\n")
+	check.Eq(t, e.SetProperty("B", dfm.Identifier("日本")), nil)
+	check.Eq(t, string(e.Apply()), "object O: T\n  A = 'ä'\n  B = 日本\nend\n")
+}
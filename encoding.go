@@ -0,0 +1,224 @@
+package dfm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a text encoding of DFM code.
+type Encoding int
+
+const (
+	// AutoEncoding is the default. The printer writes ASCII if possible,
+	// otherwise UTF-8 with a byte order mark, like Delphi does.
+	AutoEncoding Encoding = iota
+	// ANSI is a Windows code page, without a byte order mark. The code page is
+	// Windows-1252 unless specified otherwise, see ParseOptions.CodePage and
+	// PrintOptions.CodePage.
+	ANSI
+	// UTF8 is UTF-8 with a byte order mark.
+	UTF8
+	// UTF16LE is little endian UTF-16 with a byte order mark.
+	UTF16LE
+	// UTF16BE is big endian UTF-16 with a byte order mark.
+	UTF16BE
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case AutoEncoding:
+		return "AutoEncoding"
+	case ANSI:
+		return "ANSI"
+	case UTF8:
+		return "UTF8"
+	case UTF16LE:
+		return "UTF16LE"
+	case UTF16BE:
+		return "UTF16BE"
+	}
+	return fmt.Sprintf("Encoding(%d)", int(e))
+}
+
+var (
+	utf16leBOM = []byte{0xFF, 0xFE}
+	utf16beBOM = []byte{0xFE, 0xFF}
+)
+
+// utf16BOM returns the encoding of code if it starts with a UTF-16 byte order
+// mark.
+func utf16BOM(code []byte) (enc Encoding, ok bool) {
+	if bytes.HasPrefix(code, utf16leBOM) {
+		return UTF16LE, true
+	}
+	if bytes.HasPrefix(code, utf16beBOM) {
+		return UTF16BE, true
+	}
+	return AutoEncoding, false
+}
+
+// DetectEncoding guesses the encoding of the given text DFM code. Code that
+// starts with a byte order mark has the encoding of the byte order mark. Code
+// without byte order mark is UTF8 if it is valid UTF-8 and contains non-ASCII
+// characters. Otherwise it is ANSI, which includes pure ASCII code.
+//
+// Windows code pages rarely produce valid UTF-8 by accident, e.g. ANSI code
+// would need to have a character like 'Ã' followed by one like '¤' and never
+// one of them alone.
+func DetectEncoding(code []byte) Encoding {
+	if enc, ok := utf16BOM(code); ok {
+		return enc
+	}
+	if bytes.HasPrefix(code, utf8bom) {
+		return UTF8
+	}
+	if !allASCII(code) && utf8.Valid(code) {
+		return UTF8
+	}
+	return ANSI
+}
+
+// detectStreamEncoding is like DetectEncoding but for the start of a stream.
+// A character at the end of start might be incomplete.
+func detectStreamEncoding(start []byte) Encoding {
+	if enc := DetectEncoding(start); enc != ANSI {
+		return enc
+	}
+	// Cut off an incomplete UTF-8 sequence at the end.
+	for i := len(start) - 1; i >= 0 && i >= len(start)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(start[i]) {
+			if !utf8.FullRune(start[i:]) {
+				return DetectEncoding(start[:i])
+			}
+			break
+		}
+	}
+	return ANSI
+}
+
+// hasBOM reports whether code starts with the byte order mark of enc.
+func hasBOM(code []byte, enc Encoding) bool {
+	switch enc {
+	case UTF8:
+		return bytes.HasPrefix(code, utf8bom)
+	case UTF16LE:
+		return bytes.HasPrefix(code, utf16leBOM)
+	case UTF16BE:
+		return bytes.HasPrefix(code, utf16beBOM)
+	}
+	return false
+}
+
+// decodeUTF16 converts UTF-16 code without byte order mark to UTF-8. Unpaired
+// surrogates and a trailing odd byte become U+FFFD.
+func decodeUTF16(code []byte, enc Encoding) []byte {
+	units := make([]uint16, len(code)/2)
+	for i := range units {
+		if enc == UTF16BE {
+			units[i] = uint16(code[2*i])<<8 | uint16(code[2*i+1])
+		} else {
+			units[i] = uint16(code[2*i+1])<<8 | uint16(code[2*i])
+		}
+	}
+	out := make([]byte, 0, len(code))
+	var buf [utf8.UTFMax]byte
+	for _, r := range utf16.Decode(units) {
+		n := utf8.EncodeRune(buf[:], r)
+		out = append(out, buf[:n]...)
+	}
+	if len(code)%2 == 1 {
+		out = append(out, string(utf8.RuneError)...)
+	}
+	return out
+}
+
+// encodeUTF16 converts UTF-8 code to UTF-16, optionally with a byte order
+// mark.
+func encodeUTF16(code []byte, enc Encoding, bom bool) []byte {
+	units := utf16.Encode(bytes.Runes(code))
+	out := make([]byte, 0, 2+2*len(units))
+	if bom && enc == UTF16BE {
+		out = append(out, utf16beBOM...)
+	} else if bom {
+		out = append(out, utf16leBOM...)
+	}
+	for _, u := range units {
+		if enc == UTF16BE {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+// encode converts the printed UTF-8 code to the given encoding. For
+// AutoEncoding, bom determines whether the code is UTF-8 with byte order mark
+// or ASCII. For UTF-8 and UTF-16, bom determines whether a byte order mark is
+// written. cp is the code page for ANSI.
+func encode(code []byte, enc Encoding, cp *codePage, bom bool) ([]byte, error) {
+	switch enc {
+	case AutoEncoding, UTF8:
+		if bom {
+			return append(append([]byte{}, utf8bom...), code...), nil
+		}
+		return code, nil
+	case ANSI:
+		ansi, ok := cp.encode(string(code))
+		if !ok {
+			return nil, fmt.Errorf("dfm: the code contains characters that do not exist in %v", cp)
+		}
+		return ansi, nil
+	case UTF16LE, UTF16BE:
+		return encodeUTF16(code, enc, bom), nil
+	}
+	return nil, fmt.Errorf("dfm: unknown encoding %v", enc)
+}
+
+// utf16Reader converts a stream of UTF-16 code, without byte order mark, to
+// UTF-8.
+type utf16Reader struct {
+	r   io.Reader
+	enc Encoding
+	// in holds bytes that could not be decoded yet because the rest of their
+	// character was not read.
+	in  []byte
+	out []byte
+	err error
+}
+
+func (r *utf16Reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		var buf [4096]byte
+		n, err := r.r.Read(buf[:])
+		r.in = append(r.in, buf[:n]...)
+		r.err = err
+
+		complete := len(r.in)
+		if r.err == nil {
+			complete &^= 1
+			if complete >= 2 && isHighSurrogate(r.in[complete-2:complete], r.enc) {
+				complete -= 2
+			}
+		}
+		r.out = decodeUTF16(r.in[:complete], r.enc)
+		r.in = append(r.in[:0], r.in[complete:]...)
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func isHighSurrogate(unit []byte, enc Encoding) bool {
+	hi := unit[1]
+	if enc == UTF16BE {
+		hi = unit[0]
+	}
+	return 0xD8 <= hi && hi <= 0xDB
+}
@@ -0,0 +1,141 @@
+package dfm_test
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+// toUTF16 encodes s as UTF-16 with a byte order mark.
+func toUTF16(s string, bigEndian bool) []byte {
+	var b bytes.Buffer
+	for _, u := range utf16.Encode([]rune("\uFEFF" + s)) {
+		if bigEndian {
+			b.WriteByte(byte(u >> 8))
+			b.WriteByte(byte(u))
+		} else {
+			b.WriteByte(byte(u))
+			b.WriteByte(byte(u >> 8))
+		}
+	}
+	return b.Bytes()
+}
+
+func TestParseUTF16(t *testing.T) {
+	code := "object Förm: TForm\r\n  Caption = '日本😀'\r\nend\r\n"
+	want := &dfm.Object{
+		Name:       "Förm",
+		Type:       "TForm",
+		Properties: []dfm.Property{{Name: "Caption", Value: dfm.String("日本😀")}},
+	}
+	for _, bigEndian := range []bool{false, true} {
+		obj, err := dfm.ParseBytes(toUTF16(code, bigEndian))
+		check.Eq(t, err, nil, bigEndian)
+		check.Eq(t, obj, want, bigEndian)
+	}
+}
+
+func TestUTF16LittleEndianIsNotBinary(t *testing.T) {
+	// The UTF-16LE byte order mark starts with 0xFF like a resource header.
+	obj, err := dfm.ParseBytes(toUTF16("object A: TA\r\nend", false))
+	check.Eq(t, err, nil)
+	check.Eq(t, obj, &dfm.Object{Name: "A", Type: "TA"})
+
+	_, err = dfm.ParseBytes([]byte{0xFF, 0x0A, 0x00, 'T', 0})
+	check.Neq(t, err, nil)
+}
+
+func TestPrintWithEncoding(t *testing.T) {
+	obj := dfm.Object{Name: "Förm", Type: "TForm"}
+	code := "object Förm: TForm\r\nend\r\n"
+	tests := []struct {
+		encoding dfm.Encoding
+		want     []byte
+	}{
+		{dfm.AutoEncoding, []byte("\xEF\xBB\xBF" + code)},
+		{dfm.UTF8, []byte("\xEF\xBB\xBF" + code)},
+		{dfm.ANSI, []byte("object F\xF6rm: TForm\r\nend\r\n")},
+		{dfm.UTF16LE, toUTF16(code, false)},
+		{dfm.UTF16BE, toUTF16(code, true)},
+	}
+	for _, test := range tests {
+		have, err := obj.PrintWithOptions(dfm.PrintOptions{Encoding: test.encoding})
+		check.Eq(t, err, nil, test.encoding)
+		check.Eq(t, have, test.want, test.encoding)
+	}
+
+	ascii := dfm.Object{Name: "Form", Type: "TForm"}
+	have, err := ascii.PrintWithOptions(dfm.PrintOptions{Encoding: dfm.UTF8})
+	check.Eq(t, err, nil)
+	check.Eq(t, have, []byte("\xEF\xBB\xBFobject Form: TForm\r\nend\r\n"))
+}
+
+func TestPrintANSIFailsForCharactersOutsideWindows1252(t *testing.T) {
+	obj := dfm.Object{Name: "日本", Type: "TForm"}
+	_, err := obj.PrintWithOptions(dfm.PrintOptions{Encoding: dfm.ANSI})
+	check.Neq(t, err, nil)
+}
+
+func TestUTF16RoundTrip(t *testing.T) {
+	code := toUTF16("object Förm: TForm\r\n  Caption = 'Hi'\r\nend\r\n", true)
+	obj, err := dfm.ParseBytesWithOptions(code, dfm.ParseOptions{Lossless: true})
+	check.Eq(t, err, nil)
+	have, err := obj.PrintWithOptions(dfm.PrintOptions{Encoding: dfm.UTF16BE})
+	check.Eq(t, err, nil)
+	check.Eq(t, have, code)
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		code string
+		want dfm.Encoding
+	}{
+		{"", dfm.ANSI},
+		{"object A: TA\r\nend", dfm.ANSI},
+		{"S = '\xE4'", dfm.ANSI},
+		{"S = 'ä'", dfm.UTF8},
+		{"\xEF\xBB\xBFS = 'a'", dfm.UTF8},
+		{"\xFF\xFEa\x00", dfm.UTF16LE},
+		{"\xFE\xFF\x00a", dfm.UTF16BE},
+		// Valid UTF-8 followed by an ANSI character.
+		{"ä\xE4", dfm.ANSI},
+	}
+	for _, test := range tests {
+		check.Eq(t, dfm.DetectEncoding([]byte(test.code)), test.want, test.code)
+	}
+}
+
+func TestUTF8WithoutBOMIsDetected(t *testing.T) {
+	obj, err := dfm.ParseBytes([]byte("object Förm: TA\r\n  S = 'Grüße'\r\nend"))
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Name, "Förm")
+	check.Eq(t, obj.Properties[0].Value, dfm.String("Grüße"))
+}
+
+func TestEncodingOverridesDetection(t *testing.T) {
+	// This is valid UTF-8 for ä but could also be Windows-1252 for Ã¤.
+	code := []byte("object A: TA\r\n  S = '\xC3\xA4'\r\nend")
+	obj, err := dfm.ParseBytes(code)
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Properties[0].Value, dfm.String("ä"))
+
+	obj, err = dfm.ParseBytesWithOptions(code, dfm.ParseOptions{Encoding: dfm.ANSI})
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Properties[0].Value, dfm.String("Ã¤"))
+
+	obj, err = dfm.ParseBytesWithOptions(
+		[]byte("object A: TA\r\n  S = '\xE4'\r\nend"),
+		dfm.ParseOptions{Encoding: dfm.UTF8},
+	)
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Properties[0].Value, dfm.String("�"))
+
+	// UTF-16 without byte order mark.
+	utf16 := toUTF16("object A: TA\r\nend", false)[2:]
+	obj, err = dfm.ParseBytesWithOptions(utf16, dfm.ParseOptions{Encoding: dfm.UTF16LE})
+	check.Eq(t, err, nil)
+	check.Eq(t, obj, &dfm.Object{Name: "A", Type: "TA"})
+}
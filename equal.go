@@ -0,0 +1,330 @@
+package dfm
+
+import (
+	"math"
+	"strings"
+)
+
+// EqualOptions change how Objects and values are compared by
+// Object.EqualWithOptions and EqualValuesWithOptions. The zero value compares
+// everything exactly, except for numbers, see StrictTypes.
+type EqualOptions struct {
+	// IgnoreOrder compares the properties and child objects of objects and
+	// collection items and the values of Sets regardless of their order.
+	// Collection items and Tuple values are always compared in order.
+	IgnoreOrder bool
+	// IgnoreCase compares the names of properties and objects, object types
+	// and identifiers case-insensitively, like Delphi does. Strings are always
+	// compared case-sensitively.
+	IgnoreCase bool
+	// FloatTolerance is the maximum absolute difference between two floating
+	// point values that are considered equal. This applies to Float, Extended,
+	// Single, Currency and Date values and to integers compared with them.
+	FloatTolerance float64
+	// IgnoreProperties lists the names of properties that are skipped in
+	// objects and collection items, e.g. "ExplicitWidth" or "TabOrder". The
+	// names are compared case-insensitively.
+	IgnoreProperties []string
+	// StrictTypes makes values of different types unequal, even if they have
+	// the same numeric value. By default Int(1), Int64(1), UInt64(1), Float(1),
+	// Extended 1 and Single(1) are all equal. Currency and Date values are
+	// only compared to values of their own type.
+	StrictTypes bool
+}
+
+// Equal reports whether o and other have the same header and properties and
+// equal child objects, using the zero EqualOptions. The original code of
+// Objects parsed with ParseOptions.Lossless is not compared.
+func (o *Object) Equal(other *Object) bool {
+	return o.EqualWithOptions(other, EqualOptions{})
+}
+
+// EqualWithOptions is like Equal but compares according to the given options.
+func (o *Object) EqualWithOptions(other *Object, opts EqualOptions) bool {
+	return EqualValuesWithOptions(o, other, opts)
+}
+
+// EqualValues reports whether a and b are equal, comparing Objects, Sets,
+// Tuples, Items and Bytes deeply, using the zero EqualOptions.
+func EqualValues(a, b PropertyValue) bool {
+	return EqualValuesWithOptions(a, b, EqualOptions{})
+}
+
+// EqualValuesWithOptions is like EqualValues but compares according to the
+// given options.
+func EqualValuesWithOptions(a, b PropertyValue, opts EqualOptions) bool {
+	return opts.values(a, b)
+}
+
+func (opts *EqualOptions) names(a, b string) bool {
+	if opts.IgnoreCase {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+func (opts *EqualOptions) values(a, b PropertyValue) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if opts.StrictTypes && !sameType(a, b) {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Object:
+		b, ok := b.(*Object)
+		return ok && opts.objects(a, b)
+	case String:
+		b, ok := b.(String)
+		return ok && a == b
+	case Identifier:
+		b, ok := b.(Identifier)
+		return ok && opts.names(string(a), string(b))
+	case Bool:
+		b, ok := b.(Bool)
+		return ok && a == b
+	case BadValue:
+		b, ok := b.(BadValue)
+		return ok && a == b
+	case Bytes:
+		b, ok := b.(Bytes)
+		return ok && string(a) == string(b)
+	case Set:
+		b, ok := b.(Set)
+		return ok && opts.list(a, b, opts.IgnoreOrder)
+	case Tuple:
+		b, ok := b.(Tuple)
+		return ok && opts.list(a, b, false)
+	case Items:
+		b, ok := b.(Items)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !opts.properties(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case Currency:
+		b, ok := b.(Currency)
+		return ok && opts.floats(a.Float64(), b.Float64(), a == b)
+	case Date:
+		b, ok := b.(Date)
+		return ok && opts.floats(float64(a), float64(b), a == b)
+	}
+	return opts.numbers(a, b)
+}
+
+func sameType(a, b PropertyValue) bool {
+	switch a.(type) {
+	case *Object:
+		_, ok := b.(*Object)
+		return ok
+	case Int:
+		_, ok := b.(Int)
+		return ok
+	case Int64:
+		_, ok := b.(Int64)
+		return ok
+	case UInt64:
+		_, ok := b.(UInt64)
+		return ok
+	case Float:
+		_, ok := b.(Float)
+		return ok
+	case Extended:
+		_, ok := b.(Extended)
+		return ok
+	case Single:
+		_, ok := b.(Single)
+		return ok
+	}
+	// The remaining types are compared by type in values anyway.
+	return true
+}
+
+// numbers compares integers and floating point numbers of any type by value.
+func (opts *EqualOptions) numbers(a, b PropertyValue) bool {
+	aInt, aIsInt := integerValue(a)
+	bInt, bIsInt := integerValue(b)
+	if aIsInt && bIsInt {
+		return aInt.neg == bInt.neg && aInt.abs == bInt.abs
+	}
+
+	if x, ok := a.(Extended); ok {
+		if y, ok := b.(Extended); ok && x == y {
+			return true
+		}
+	}
+	x, ok := floatValue(a)
+	if !ok {
+		return false
+	}
+	y, ok := floatValue(b)
+	return ok && opts.floats(x, y, x == y)
+}
+
+func (opts *EqualOptions) floats(a, b float64, exact bool) bool {
+	return exact || math.Abs(a-b) <= opts.FloatTolerance
+}
+
+type integer struct {
+	neg bool
+	abs uint64
+}
+
+func integerValue(v PropertyValue) (integer, bool) {
+	signed := func(i int64) integer {
+		if i < 0 {
+			return integer{neg: true, abs: uint64(-(i + 1)) + 1}
+		}
+		return integer{abs: uint64(i)}
+	}
+	switch v := v.(type) {
+	case Int:
+		return signed(int64(v)), true
+	case Int64:
+		return signed(int64(v)), true
+	case UInt64:
+		return integer{abs: uint64(v)}, true
+	}
+	return integer{}, false
+}
+
+// floatValue converts integers and floating point numbers, but not Currency and
+// Date, to float64.
+func floatValue(v PropertyValue) (float64, bool) {
+	switch v := v.(type) {
+	case Int:
+		return float64(v), true
+	case Int64:
+		return float64(v), true
+	case UInt64:
+		return float64(v), true
+	case Float:
+		return float64(v), true
+	case Extended:
+		return v.Float64(), true
+	case Single:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// list compares the values of a Set or Tuple.
+func (opts *EqualOptions) list(a, b []PropertyValue, ignoreOrder bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if !ignoreOrder {
+		for i := range a {
+			if !opts.values(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return matchAll(len(a), func(i, j int) bool {
+		return opts.values(a[i], b[j])
+	})
+}
+
+func (opts *EqualOptions) objects(a, b *Object) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return opts.names(a.Name, b.Name) &&
+		opts.names(a.Type, b.Type) &&
+		a.Kind == b.Kind &&
+		a.HasIndex == b.HasIndex &&
+		(!a.HasIndex || a.Index == b.Index) &&
+		opts.properties(a.Properties, b.Properties)
+}
+
+// properties compares the properties of objects or collection items.
+func (opts *EqualOptions) properties(a, b []Property) bool {
+	a, b = opts.withoutIgnored(a), opts.withoutIgnored(b)
+	if len(a) != len(b) {
+		return false
+	}
+	equal := func(i, j int) bool {
+		return opts.names(a[i].Name, b[j].Name) && opts.values(a[i].Value, b[j].Value)
+	}
+	if !opts.IgnoreOrder {
+		for i := range a {
+			if !equal(i, i) {
+				return false
+			}
+		}
+		return true
+	}
+	return matchAll(len(a), equal)
+}
+
+func (opts *EqualOptions) withoutIgnored(props []Property) []Property {
+	if len(opts.IgnoreProperties) == 0 {
+		return props
+	}
+	var kept []Property
+	for _, prop := range props {
+		ignored := false
+		if _, isObject := prop.Value.(*Object); !isObject {
+			for _, name := range opts.IgnoreProperties {
+				if strings.EqualFold(prop.Name, name) {
+					ignored = true
+					break
+				}
+			}
+		}
+		if !ignored {
+			kept = append(kept, prop)
+		}
+	}
+	return kept
+}
+
+// matchAll reports whether each of n elements in a list has an equal element in
+// another list of length n, each used only once. Equality is not transitive if
+// there is a float tolerance, so a greedy pairing is not enough. Instead this
+// finds a maximum bipartite matching with augmenting paths.
+func matchAll(n int, equal func(i, j int) bool) bool {
+	edges := make([][]int, n)
+	for i := range edges {
+		for j := 0; j < n; j++ {
+			if equal(i, j) {
+				edges[i] = append(edges[i], j)
+			}
+		}
+		if len(edges[i]) == 0 {
+			return false
+		}
+	}
+
+	// matchOf[j] is the element i that j is paired with, or -1.
+	matchOf := make([]int, n)
+	for j := range matchOf {
+		matchOf[j] = -1
+	}
+	var augment func(i int, visited []bool) bool
+	augment = func(i int, visited []bool) bool {
+		for _, j := range edges[i] {
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			if matchOf[j] == -1 || augment(matchOf[j], visited) {
+				matchOf[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i := 0; i < n; i++ {
+		if !augment(i, make([]bool, n)) {
+			return false
+		}
+	}
+	return true
+}
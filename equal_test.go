@@ -0,0 +1,165 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestEqualObjects(t *testing.T) {
+	code := `object Form1: TForm1
+  Left = 10
+  Anchors = [akLeft, akTop]
+  Columns = <
+    item
+      Width = 5
+    end>
+  object Panel1: TPanel [2]
+    Caption = 'a'
+  end
+end`
+	a, err := dfm.ParseString(code)
+	check.Eq(t, err, nil)
+	b, err := dfm.ParseString(code)
+	check.Eq(t, err, nil)
+	check.Eq(t, a.Equal(b), true)
+	check.Eq(t, a.Equal(a.Clone()), true)
+
+	changes := []func(o *dfm.Object){
+		func(o *dfm.Object) { o.Name = "Form2" },
+		func(o *dfm.Object) { o.Kind = dfm.Inherited },
+		func(o *dfm.Object) { o.Properties[0].Value = dfm.Int(11) },
+		func(o *dfm.Object) { o.Properties[0].Name = "Top" },
+		func(o *dfm.Object) { o.Properties = o.Properties[1:] },
+		func(o *dfm.Object) { o.Properties[1].Value = dfm.Set{dfm.Identifier("akLeft")} },
+		func(o *dfm.Object) { o.Properties[2].Value.(dfm.Items)[0][0].Value = dfm.Int(6) },
+		func(o *dfm.Object) { o.Child("Panel1").Index = 3 },
+		func(o *dfm.Object) { o.Child("Panel1").Properties[0].Value = dfm.String("A") },
+	}
+	for i, change := range changes {
+		c := a.Clone()
+		change(c)
+		check.Eq(t, a.Equal(c), false, i)
+		check.Eq(t, c.Equal(a), false, i)
+	}
+}
+
+func TestEqualValuesComparesNumbersByValue(t *testing.T) {
+	ext, _ := dfm.ParseExtended("1.5")
+	for _, v := range []dfm.PropertyValue{
+		dfm.Int64(1), dfm.UInt64(1), dfm.Float(1), dfm.Single(1),
+	} {
+		check.Eq(t, dfm.EqualValues(dfm.Int(1), v), true, v)
+		check.Eq(t, dfm.EqualValuesWithOptions(dfm.Int(1), v,
+			dfm.EqualOptions{StrictTypes: true}), false, v)
+	}
+	check.Eq(t, dfm.EqualValues(ext, dfm.Float(1.5)), true)
+	check.Eq(t, dfm.EqualValues(dfm.Int(-1), dfm.UInt64(1<<64-1)), false)
+	check.Eq(t, dfm.EqualValues(dfm.Int(1), dfm.Currency(10000)), false)
+	check.Eq(t, dfm.EqualValues(dfm.Int(1), dfm.Date(1)), false)
+	check.Eq(t, dfm.EqualValues(dfm.Int(1), dfm.String("1")), false)
+	check.Eq(t, dfm.EqualValues(dfm.Int(1), dfm.Bool(true)), false)
+	check.Eq(t, dfm.EqualValues(nil, nil), true)
+	check.Eq(t, dfm.EqualValues(nil, dfm.Int(0)), false)
+}
+
+func TestEqualFloatTolerance(t *testing.T) {
+	opts := dfm.EqualOptions{FloatTolerance: 0.01}
+	tenth := 0.1
+	check.Eq(t, dfm.EqualValues(dfm.Float(tenth+0.2), dfm.Float(0.3)), false)
+	check.Eq(t, dfm.EqualValuesWithOptions(dfm.Float(tenth+0.2), dfm.Float(0.3), opts), true)
+	check.Eq(t, dfm.EqualValuesWithOptions(dfm.Float(1.005), dfm.Int(1), opts), true)
+	check.Eq(t, dfm.EqualValuesWithOptions(dfm.Single(1.1), dfm.Float(1.1), opts), true)
+	check.Eq(t, dfm.EqualValuesWithOptions(dfm.Date(1.001), dfm.Date(1), opts), true)
+	check.Eq(t, dfm.EqualValuesWithOptions(dfm.Currency(10001), dfm.Currency(10000), opts), true)
+	check.Eq(t, dfm.EqualValuesWithOptions(dfm.Float(1.02), dfm.Float(1), opts), false)
+}
+
+func TestEqualIgnoringOrder(t *testing.T) {
+	a := &dfm.Object{Type: "T", Properties: []dfm.Property{
+		{Name: "A", Value: dfm.Int(1)},
+		{Name: "B", Value: dfm.Set{dfm.Identifier("x"), dfm.Identifier("y")}},
+		{Name: "C", Value: dfm.Tuple{dfm.Int(1), dfm.Int(2)}},
+		{Name: "P", Value: &dfm.Object{Name: "P", Type: "TP"}},
+	}}
+	b := &dfm.Object{Type: "T", Properties: []dfm.Property{
+		{Name: "P", Value: &dfm.Object{Name: "P", Type: "TP"}},
+		{Name: "C", Value: dfm.Tuple{dfm.Int(1), dfm.Int(2)}},
+		{Name: "B", Value: dfm.Set{dfm.Identifier("y"), dfm.Identifier("x")}},
+		{Name: "A", Value: dfm.Int(1)},
+	}}
+	opts := dfm.EqualOptions{IgnoreOrder: true}
+	check.Eq(t, a.Equal(b), false)
+	check.Eq(t, a.EqualWithOptions(b, opts), true)
+
+	// Tuples are ordered.
+	b.Properties[1].Value = dfm.Tuple{dfm.Int(2), dfm.Int(1)}
+	check.Eq(t, a.EqualWithOptions(b, opts), false)
+	// Duplicates must match one to one.
+	b.Properties[1].Value = dfm.Tuple{dfm.Int(1), dfm.Int(2)}
+	b.Properties[3].Name = "B"
+	check.Eq(t, a.EqualWithOptions(b, opts), false)
+}
+
+func TestEqualIgnoringOrderFindsPairsWithinTolerance(t *testing.T) {
+	opts := dfm.EqualOptions{IgnoreOrder: true, FloatTolerance: 0.35}
+	// A greedy pairing would match 1.2 with 1.0 and find nothing for 1.0.
+	a := dfm.Set{dfm.Float(1.2), dfm.Float(1.0)}
+	b := dfm.Set{dfm.Float(1.0), dfm.Float(1.5)}
+	check.Eq(t, dfm.EqualValuesWithOptions(a, b, opts), true)
+	check.Eq(t, dfm.EqualValuesWithOptions(b, a, opts), true)
+
+	x := &dfm.Object{Type: "T", Properties: []dfm.Property{
+		{Name: "X", Value: dfm.Float(1.2)},
+		{Name: "X", Value: dfm.Float(1.0)},
+	}}
+	y := &dfm.Object{Type: "T", Properties: []dfm.Property{
+		{Name: "X", Value: dfm.Float(1.0)},
+		{Name: "X", Value: dfm.Float(1.5)},
+	}}
+	check.Eq(t, x.EqualWithOptions(y, opts), true)
+
+	b[0] = dfm.Float(1.6)
+	check.Eq(t, dfm.EqualValuesWithOptions(a, b, opts), false)
+}
+
+func TestEqualIgnoringCase(t *testing.T) {
+	a := &dfm.Object{Name: "Form1", Type: "TForm1", Properties: []dfm.Property{
+		{Name: "Align", Value: dfm.Identifier("alClient")},
+		{Name: "Caption", Value: dfm.String("Hello")},
+	}}
+	b := &dfm.Object{Name: "FORM1", Type: "tform1", Properties: []dfm.Property{
+		{Name: "align", Value: dfm.Identifier("ALCLIENT")},
+		{Name: "CAPTION", Value: dfm.String("Hello")},
+	}}
+	opts := dfm.EqualOptions{IgnoreCase: true}
+	check.Eq(t, a.Equal(b), false)
+	check.Eq(t, a.EqualWithOptions(b, opts), true)
+
+	b.Properties[1].Value = dfm.String("HELLO")
+	check.Eq(t, a.EqualWithOptions(b, opts), false)
+}
+
+func TestEqualIgnoringProperties(t *testing.T) {
+	a := &dfm.Object{Type: "T", Properties: []dfm.Property{
+		{Name: "Left", Value: dfm.Int(1)},
+		{Name: "ExplicitLeft", Value: dfm.Int(1)},
+		{Name: "Columns", Value: dfm.Items{{
+			{Name: "Width", Value: dfm.Int(5)},
+			{Name: "Tag", Value: dfm.Int(1)},
+		}}},
+	}}
+	b := &dfm.Object{Type: "T", Properties: []dfm.Property{
+		{Name: "Left", Value: dfm.Int(1)},
+		{Name: "Columns", Value: dfm.Items{{
+			{Name: "Width", Value: dfm.Int(5)},
+			{Name: "Tag", Value: dfm.Int(2)},
+		}}},
+		{Name: "ExplicitTop", Value: dfm.Int(3)},
+	}}
+	check.Eq(t, a.Equal(b), false)
+	check.Eq(t, a.EqualWithOptions(b, dfm.EqualOptions{
+		IgnoreProperties: []string{"explicitleft", "ExplicitTop", "Tag"},
+	}), true)
+}
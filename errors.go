@@ -0,0 +1,71 @@
+package dfm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseError describes a syntax error in a text DFM. It is returned by all
+// Parse functions when the code is malformed.
+type ParseError struct {
+	// FileName is the name set in ParseOptions or the path given to ParseFile.
+	// It might be empty.
+	FileName string
+	// Position is the location of the offending token.
+	Position
+	// Token is the text of the offending token. It is empty at the end of the
+	// file.
+	Token string
+	// Expected lists what would have been valid instead of Token, e.g.
+	// `"end"`, "identifier" or "'='". It might be empty if the token itself is
+	// malformed, e.g. an integer literal that is out of range.
+	Expected []string
+	// Msg describes the error without the location.
+	Msg string
+}
+
+// Error returns the error message prefixed with the location, e.g.
+//
+//     Form1.dfm:123:5: "end" expected but was "x"
+func (e *ParseError) Error() string {
+	if e.FileName == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Line, e.Col, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.FileName, e.Line, e.Col, e.Msg)
+}
+
+// expectedButWas formats the common message for unexpected tokens.
+func expectedButWas(expected []string, t token) string {
+	was := strconv.Quote(t.text)
+	if t.tokenType == tokenEOF {
+		was = "end of file"
+	}
+	return strings.Join(expected, " or ") + " expected but was " + was
+}
+
+// ErrorList is returned by the Parse functions if ParseOptions.Recover is set.
+// It contains all errors in the order they were found.
+type ErrorList []*ParseError
+
+// Error returns the first error message and the number of further errors.
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	case 2:
+		return list[0].Error() + " (and 1 more error)"
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", list[0], len(list)-1)
+	}
+}
+
+// Err returns nil if the list is empty and the list itself otherwise.
+func (list ErrorList) Err() error {
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
@@ -0,0 +1,205 @@
+package dfm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Extended is Delphi's 80 bit extended precision floating point number, which
+// is how Delphi stores floating point properties in DFMs. Unlike Float, it
+// keeps all 64 bits of the mantissa, so values parsed from DFMs are printed
+// with all their digits and are written to binary DFMs unchanged.
+//
+// The parser returns Extended values instead of Float if
+// ParseOptions.ExactFloats is set.
+type Extended struct {
+	// SignExp holds the sign in its highest bit and the exponent, biased by
+	// 16383, in the lower 15 bits.
+	SignExp uint16
+	// Mantissa is the mantissa including the explicit integer bit.
+	Mantissa uint64
+}
+
+const extendedBias = 16383
+
+// ExtendedFromFloat64 converts f to an Extended. Every float64 can be
+// represented exactly.
+func ExtendedFromFloat64(f float64) Extended {
+	bits := math.Float64bits(f)
+	signExp := uint16(bits>>48) & 0x8000
+	exp := int(bits>>52) & 0x7FF
+	frac := bits & (1<<52 - 1)
+
+	var mantissa uint64
+	switch {
+	case exp == 0x7FF:
+		signExp |= 0x7FFF
+		mantissa = 1<<63 | frac<<11
+	case exp == 0 && frac == 0:
+		// Zero keeps its sign.
+	case exp == 0:
+		// Denormalized float64 values are normal in the Extended format.
+		shift := 0
+		for frac&(1<<52) == 0 {
+			frac <<= 1
+			shift++
+		}
+		signExp |= uint16(1 - 1023 - shift + extendedBias)
+		mantissa = frac << 11
+	default:
+		signExp |= uint16(exp - 1023 + extendedBias)
+		mantissa = 1<<63 | frac<<11
+	}
+	return Extended{SignExp: signExp, Mantissa: mantissa}
+}
+
+// ParseExtended converts a decimal number like "1.5", "-2" or "5.5E-10" to
+// the nearest Extended value.
+func ParseExtended(s string) (Extended, error) {
+	fail := func(msg string) (Extended, error) {
+		return Extended{}, fmt.Errorf("dfm.ParseExtended: %q %s", s, msg)
+	}
+
+	// Very large exponents would make big.Rat compute huge powers of 10. They
+	// are out of range anyway, unless the digits are all 0.
+	if e := strings.IndexAny(s, "eE"); e != -1 {
+		exp, err := strconv.Atoi(strings.TrimPrefix(s[e+1:], "+"))
+		if err != nil {
+			return fail("is not a number")
+		}
+		zero := strings.Trim(s[:e], "+-0.") == ""
+		if zero || exp < -6000 {
+			var x Extended
+			if strings.HasPrefix(s, "-") {
+				x.SignExp = 0x8000
+			}
+			return x, nil
+		}
+		if exp > 6000 {
+			return fail("does not fit into an Extended")
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return fail("is not a number")
+	}
+	f := new(big.Float).SetPrec(64).SetMode(big.ToNearestEven).SetRat(r)
+	if r.Sign() == 0 && strings.HasPrefix(s, "-") {
+		f.Neg(f)
+	}
+	x, err := extendedFromBig(f)
+	if err != nil {
+		return fail(err.Error())
+	}
+	return x, nil
+}
+
+// extendedFromBig converts f, which must have a precision of 64 bits or less,
+// to an Extended.
+func extendedFromBig(f *big.Float) (Extended, error) {
+	var x Extended
+	if f.Signbit() {
+		x.SignExp = 0x8000
+	}
+	if f.Sign() == 0 {
+		return x, nil
+	}
+
+	// MantExp has the mantissa in [0.5, 1), Extended in [1, 2).
+	biased := f.MantExp(nil) - 1 + extendedBias
+	if biased <= 0 {
+		// The value is denormalized, it has fewer bits of precision.
+		prec := 64 - (1 - biased)
+		if prec <= 0 {
+			return x, nil
+		}
+		f = new(big.Float).SetMode(big.ToNearestEven).SetPrec(uint(prec)).Set(f)
+		biased = f.MantExp(nil) - 1 + extendedBias
+	}
+	if biased >= 0x7FFF {
+		return x, errors.New("does not fit into an Extended")
+	}
+
+	exp := biased
+	if biased <= 0 {
+		// Denormalized numbers have an implicit exponent of 1-16383.
+		exp = 1
+	} else {
+		x.SignExp |= uint16(biased)
+	}
+	abs := new(big.Float).Abs(f)
+	x.Mantissa, _ = abs.SetMantExp(abs, 63-(exp-extendedBias)).Uint64()
+	return x, nil
+}
+
+// extendedFromBytes decodes an Extended that is stored in little endian byte
+// order, with the mantissa in the first 8 bytes.
+func extendedFromBytes(b []byte) Extended {
+	return Extended{
+		Mantissa: binary.LittleEndian.Uint64(b[:8]),
+		SignExp:  binary.LittleEndian.Uint16(b[8:]),
+	}
+}
+
+// bytes encodes x in little endian byte order, the way binary DFMs store it.
+func (x Extended) bytes() []byte {
+	b := make([]byte, 10)
+	binary.LittleEndian.PutUint64(b, x.Mantissa)
+	binary.LittleEndian.PutUint16(b[8:], x.SignExp)
+	return b
+}
+
+// Float64 returns the nearest float64 to x.
+func (x Extended) Float64() float64 {
+	sign := 1.0
+	if x.SignExp&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(x.SignExp & 0x7FFF)
+	if exp == 0x7FFF {
+		if x.Mantissa<<1 == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	if exp == 0 {
+		// Denormalized numbers have an implicit exponent of 1-16383.
+		exp = 1
+	}
+	return sign * math.Ldexp(float64(x.Mantissa), exp-extendedBias-63)
+}
+
+// IsFinite reports whether x is neither NaN nor infinite.
+func (x Extended) IsFinite() bool {
+	return x.SignExp&0x7FFF != 0x7FFF
+}
+
+// big returns x as a big.Float with 64 bits of precision. x must be finite.
+func (x Extended) big() *big.Float {
+	exp := int(x.SignExp & 0x7FFF)
+	if exp == 0 {
+		exp = 1
+	}
+	f := new(big.Float).SetPrec(64).SetUint64(x.Mantissa)
+	f.SetMantExp(f, exp-extendedBias-63)
+	if x.SignExp&0x8000 != 0 {
+		f.Neg(f)
+	}
+	return f
+}
+
+// String returns the shortest decimal number that parses back to x, with
+// exponents written like Delphi does, e.g. "0.1", "-2" or "1.5E300". NaN and
+// infinite values are "NaN", "+Inf" and "-Inf".
+func (x Extended) String() string {
+	if !x.IsFinite() {
+		return strconv.FormatFloat(x.Float64(), 'g', -1, 64)
+	}
+	return delphiExponent(x.big().Text('g', -1))
+}
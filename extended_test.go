@@ -0,0 +1,93 @@
+package dfm_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestParseExtended(t *testing.T) {
+	tests := []struct {
+		text string
+		want dfm.Extended
+	}{
+		{"0", dfm.Extended{}},
+		{"-0.0", dfm.Extended{SignExp: 0x8000}},
+		{"1", dfm.Extended{SignExp: 0x3FFF, Mantissa: 1 << 63}},
+		{"-2", dfm.Extended{SignExp: 0xC000, Mantissa: 1 << 63}},
+		{"0.1", dfm.Extended{SignExp: 0x3FFB, Mantissa: 0xCCCCCCCCCCCCCCCD}},
+		{"1.5E1", dfm.Extended{SignExp: 0x4002, Mantissa: 0xF000000000000000}},
+		{"1.18973149535723176502E4932", dfm.Extended{SignExp: 0x7FFE, Mantissa: math.MaxUint64}},
+		{"3.6451995318824746025E-4951", dfm.Extended{Mantissa: 1}},
+		{"1E-99999", dfm.Extended{}},
+	}
+	for _, test := range tests {
+		x, err := dfm.ParseExtended(test.text)
+		check.Eq(t, err, nil, test.text)
+		check.Eq(t, x, test.want, test.text)
+	}
+}
+
+func TestParseExtendedErrors(t *testing.T) {
+	for _, text := range []string{"", "abc", "1E5000", "1E99999"} {
+		_, err := dfm.ParseExtended(text)
+		check.Neq(t, err, nil, text)
+	}
+}
+
+func TestExtendedStringIsShortestRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"0", "0.1", "-2.5", "0.123456789012345678", "1.1E4000", "1E-4000",
+	} {
+		x, err := dfm.ParseExtended(text)
+		check.Eq(t, err, nil)
+		check.Eq(t, x.String(), text)
+	}
+	check.Eq(t, dfm.Extended{SignExp: 0x7FFF, Mantissa: 1 << 63}.String(), "+Inf")
+}
+
+func TestExtendedFromAndToFloat64(t *testing.T) {
+	for _, f := range []float64{0, 1.5, -0.1, 1e300, 5e-324, math.MaxFloat64} {
+		check.Eq(t, dfm.ExtendedFromFloat64(f).Float64(), f)
+	}
+	check.Eq(t, dfm.ExtendedFromFloat64(0.1),
+		dfm.Extended{SignExp: 0x3FFB, Mantissa: 0xCCCCCCCCCCCCD000})
+}
+
+func TestExactFloatsKeepAllDigits(t *testing.T) {
+	code := "object A: TA\r\n" +
+		"  X = 0.123456789012345678\r\n" +
+		"  Y = -1.100000000000000000\r\n" +
+		"  Z = 1.1E4000\r\n" +
+		"end\r\n"
+	obj, err := dfm.ParseStringWithOptions(code, dfm.ParseOptions{ExactFloats: true})
+	check.Eq(t, err, nil)
+	y, _ := dfm.ParseExtended("-1.1")
+	check.Eq(t, obj.Properties[1].Value, y)
+	check.Eq(t, obj.String(), code)
+
+	// Float loses digits.
+	obj, err = dfm.ParseString("object A: TA\r\n  X = 0.123456789012345678\r\nend")
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Properties[0].Value, dfm.Float(0.12345678901234568))
+}
+
+func TestExtendedIsWrittenToBinaryDFMsExactly(t *testing.T) {
+	x, _ := dfm.ParseExtended("0.1")
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{{Name: "X", Value: x}}}
+	var bin bytes.Buffer
+	check.Eq(t, obj.WriteBinaryTo(&bin), nil)
+	check.Eq(t, bin.Bytes(), []byte("TPF0\x02TA\x00\x01X\x05"+
+		"\xCD\xCC\xCC\xCC\xCC\xCC\xCC\xCC\xFB\x3F\x00\x00"))
+
+	parsed, err := dfm.ParseBytesWithOptions(bin.Bytes(), dfm.ParseOptions{ExactFloats: true})
+	check.Eq(t, err, nil)
+	check.Eq(t, parsed.Properties[0].Value, x)
+
+	parsed, err = dfm.ParseBytes(bin.Bytes())
+	check.Eq(t, err, nil)
+	check.Eq(t, parsed.Properties[0].Value, dfm.Float(0.1))
+}
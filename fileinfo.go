@@ -0,0 +1,78 @@
+package dfm
+
+import "bytes"
+
+// FileInfo describes how text DFM code was encoded. Pass a pointer to a
+// FileInfo in ParseOptions to have it filled by the parser. Use its
+// PrintOptions to write the Object the same way again.
+//
+// FileInfo is only filled for text DFMs, not for binary ones.
+type FileInfo struct {
+	// Encoding is the detected encoding or ParseOptions.Encoding if that was
+	// set.
+	Encoding Encoding
+	// CodePage is the Windows code page of ANSI code, see
+	// ParseOptions.CodePage.
+	CodePage int
+	// BOM is true if the code starts with a byte order mark.
+	BOM bool
+	// Newline is the first line break in the code, "\r\n", "\n" or "\r". It is
+	// empty if the code is a single line.
+	Newline string
+	// ASCII is true if the code contains only ASCII characters.
+	ASCII bool
+}
+
+// PrintOptions returns options that print code in the same encoding, with the
+// same byte order mark and line breaks as described by the FileInfo.
+//
+// Pure ASCII code without byte order mark is printed like Delphi would print
+// it, as ASCII or, if unicode characters were added, as UTF-8 with byte order
+// mark.
+func (f FileInfo) PrintOptions() PrintOptions {
+	opts := PrintOptions{
+		Encoding: f.Encoding,
+		CodePage: f.CodePage,
+		BOM:      NeverBOM,
+		Newline:  f.Newline,
+	}
+	if f.BOM {
+		opts.BOM = AlwaysBOM
+	}
+	if f.ASCII && !f.BOM && (f.Encoding == ANSI || f.Encoding == UTF8) {
+		opts.Encoding = AutoEncoding
+		opts.BOM = AutoBOM
+	}
+	return opts
+}
+
+// newFileInfo describes the given code, which must not include the byte order
+// mark and must be converted to UTF-8 if it was UTF-16.
+func newFileInfo(code []byte, enc Encoding, codePage int, bom bool) FileInfo {
+	info := FileInfo{
+		Encoding: enc,
+		BOM:      bom,
+		ASCII:    allASCII(code),
+	}
+	if enc == ANSI {
+		info.CodePage = codePage
+	}
+	info.Newline = firstNewline(code)
+	return info
+}
+
+// firstNewline returns the first line break in code, "\r\n", "\n" or "\r", or
+// "" if there is none.
+func firstNewline(code []byte) string {
+	i := bytes.IndexAny(code, "\r\n")
+	switch {
+	case i == -1:
+		return ""
+	case code[i] == '\n':
+		return "\n"
+	case i+1 < len(code) && code[i+1] == '\n':
+		return "\r\n"
+	default:
+		return "\r"
+	}
+}
@@ -0,0 +1,148 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestParseFillsFileInfo(t *testing.T) {
+	tests := []struct {
+		code     []byte
+		codePage int
+		want     dfm.FileInfo
+	}{
+		{
+			code: []byte("object A: TA\r\nend"),
+			want: dfm.FileInfo{Encoding: dfm.ANSI, Newline: "\r\n", ASCII: true},
+		},
+		{
+			code: []byte("\xEF\xBB\xBFobject A: TA\nend"),
+			want: dfm.FileInfo{Encoding: dfm.UTF8, BOM: true, Newline: "\n", ASCII: true},
+		},
+		{
+			code: []byte("object Ä: TA\rend"),
+			want: dfm.FileInfo{Encoding: dfm.UTF8, Newline: "\r"},
+		},
+		{
+			code:     []byte("object \xA3: TA end"),
+			codePage: 1250,
+			want:     dfm.FileInfo{Encoding: dfm.ANSI, CodePage: 1250},
+		},
+		{
+			code: toUTF16("object A: TA\nend\r\n", true),
+			want: dfm.FileInfo{Encoding: dfm.UTF16BE, BOM: true, Newline: "\n", ASCII: true},
+		},
+	}
+	for _, test := range tests {
+		var info dfm.FileInfo
+		_, err := dfm.ParseBytesWithOptions(test.code, dfm.ParseOptions{
+			FileInfo: &info,
+			CodePage: test.codePage,
+		})
+		check.Eq(t, err, nil, string(test.code))
+		check.Eq(t, info, test.want, string(test.code))
+	}
+}
+
+func TestFileInfoReproducesEncodingBOMAndNewlines(t *testing.T) {
+	code := "\xEF\xBB\xBFobject A: TA\n  Caption = 'ä'\n  X = 1\nend\n"
+	var info dfm.FileInfo
+	obj, err := dfm.ParseBytesWithOptions([]byte(code), dfm.ParseOptions{
+		FileInfo: &info,
+		Lossless: true,
+	})
+	check.Eq(t, err, nil)
+	obj.Properties[1].Value = dfm.Int(2)
+	obj.Properties = append(obj.Properties, dfm.Property{Name: "Y", Value: dfm.Int(3)})
+	have, err := obj.PrintWithOptions(info.PrintOptions())
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "\xEF\xBB\xBFobject A: TA\n  Caption = 'ä'\n  X = 2\n  Y = 3\nend\n")
+
+	// Without lossless parsing, the new code has the same newlines and BOM.
+	obj, err = dfm.ParseBytes([]byte(code))
+	check.Eq(t, err, nil)
+	have, err = obj.PrintWithOptions(info.PrintOptions())
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "\xEF\xBB\xBFobject A: TA\n  Caption = #228\n  X = 1\nend\n")
+}
+
+func TestFileInfoReproducesUTF8WithoutBOMAndANSI(t *testing.T) {
+	for _, code := range [][]byte{
+		[]byte("object Ä: TA\nend\n"),
+		[]byte("object \xC4: TA\nend\n"),
+		toUTF16("object A: TA\nend\n", false)[2:],
+	} {
+		var info dfm.FileInfo
+		opts := dfm.ParseOptions{FileInfo: &info}
+		if code[1] == 0 {
+			opts.Encoding = dfm.UTF16LE
+		}
+		obj, err := dfm.ParseBytesWithOptions(code, opts)
+		check.Eq(t, err, nil)
+		have, err := obj.PrintWithOptions(info.PrintOptions())
+		check.Eq(t, err, nil)
+		check.Eq(t, have, code)
+	}
+}
+
+func TestFileInfoForASCIIAllowsUnicode(t *testing.T) {
+	var info dfm.FileInfo
+	obj, err := dfm.ParseBytesWithOptions([]byte("object A: TA\r\nend\r\n"), dfm.ParseOptions{
+		FileInfo: &info,
+	})
+	check.Eq(t, err, nil)
+	obj.Name = "日本"
+	have, err := obj.PrintWithOptions(info.PrintOptions())
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "\xEF\xBB\xBFobject 日本: TA\r\nend\r\n")
+}
+
+func TestPrintBOMPolicy(t *testing.T) {
+	ascii := dfm.Object{Type: "TA"}
+	unicode := dfm.Object{Type: "TÄ"}
+	tests := []struct {
+		obj  dfm.Object
+		opts dfm.PrintOptions
+		want string
+	}{
+		{ascii, dfm.PrintOptions{}, "object TA\r\nend\r\n"},
+		{ascii, dfm.PrintOptions{BOM: dfm.AlwaysBOM}, "\xEF\xBB\xBFobject TA\r\nend\r\n"},
+		{unicode, dfm.PrintOptions{BOM: dfm.NeverBOM}, "object TÄ\r\nend\r\n"},
+		{ascii, dfm.PrintOptions{Encoding: dfm.UTF8, BOM: dfm.NeverBOM}, "object TA\r\nend\r\n"},
+		{ascii, dfm.PrintOptions{Encoding: dfm.ANSI, BOM: dfm.AlwaysBOM}, "object TA\r\nend\r\n"},
+		{
+			ascii,
+			dfm.PrintOptions{Encoding: dfm.UTF16LE, BOM: dfm.NeverBOM},
+			string(toUTF16("object TA\r\nend\r\n", false)[2:]),
+		},
+	}
+	for _, test := range tests {
+		have, err := test.obj.PrintWithOptions(test.opts)
+		check.Eq(t, err, nil)
+		check.Eq(t, string(have), test.want, test.opts)
+	}
+}
+
+func TestPrintNewline(t *testing.T) {
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{
+		{Name: "S", Value: dfm.String("0123456789012345678901234567890123456789012345678901234567890123456789")},
+		{Name: "B", Value: dfm.Bytes{1}},
+		{Name: "I", Value: dfm.Items{{{Name: "X", Value: dfm.Int(1)}}}},
+	}}
+	have, err := obj.PrintWithOptions(dfm.PrintOptions{Newline: "\n"})
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), `object TA
+  S = 
+    '0123456789012345678901234567890123456789012345678901234567890123' +
+    '456789'
+  B = {
+    01}
+  I = <
+    item
+      X = 1
+    end>
+end
+`)
+}
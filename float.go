@@ -0,0 +1,218 @@
+package dfm
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// The functions in this file emulate how Delphi converts floating point
+// numbers to text when writing DFMs. Delphi's ObjectBinaryToText writes
+// Extended values with
+//
+//     FloatToStrF(Value, ffFixed, 16, 18)
+//
+// and Single, Date and Currency values with FloatToStr, which is
+//
+//     FloatToStrF(Value, ffGeneral, 15, 0)
+//
+// Both first round the value to the given precision of significant decimal
+// digits, rounding halves away from zero, and then lay out the digits.
+
+// delphiFixed formats f like FloatToStrF(f, ffFixed, precision, 18). The number
+// is written with exactly 18 digits after the dot, digits beyond the precision
+// are 0. Numbers with more than precision digits before the dot are formatted
+// like delphiGeneral instead, e.g. 1E16 for precision 16.
+func delphiFixed(f *big.Float, precision int) string {
+	const decimals = 18
+	digits, exp := decimalDigits(f, precision, decimals)
+	if exp > precision {
+		return withDot(delphiGeneral(f, precision))
+	}
+
+	var s strings.Builder
+	if f.Signbit() && digits != "" {
+		s.WriteByte('-')
+	}
+	digit := func(i int) byte {
+		if 0 <= i && i < len(digits) {
+			return digits[i]
+		}
+		return '0'
+	}
+	if exp <= 0 {
+		s.WriteByte('0')
+	}
+	for i := 0; i < exp; i++ {
+		s.WriteByte(digit(i))
+	}
+	s.WriteByte('.')
+	for i := 0; i < decimals; i++ {
+		s.WriteByte(digit(exp + i))
+	}
+	return s.String()
+}
+
+// exactFixed formats f like delphiFixed with precision 16 but keeps the
+// shortest digits that identify f exactly instead of rounding them. There are
+// at least 18 digits after the dot, more if necessary. Numbers with more than 16
+// digits before the dot use E notation, as do numbers below 0.0001 whose digits
+// do not fit into 18 decimals, e.g. 1E-30.
+func exactFixed(f *big.Float) string {
+	const precision, decimals = 16, 18
+	// Text returns something like -1.25e+20, make it 0.125 * 10^21.
+	text := f.Text('e', -1)
+	e := strings.IndexByte(text, 'e')
+	exp, _ := strconv.Atoi(text[e+1:])
+	exp++
+	sign := ""
+	mantissa := text[:e]
+	if strings.HasPrefix(mantissa, "-") {
+		sign, mantissa = "-", mantissa[1:]
+	}
+	digits := strings.TrimRight(strings.Replace(mantissa, ".", "", 1), "0")
+	if digits == "" {
+		return sign + "0." + strings.Repeat("0", decimals)
+	}
+
+	if exp > precision || (exp < -3 && len(digits)-exp > decimals) {
+		s := sign + digits[:1]
+		if len(digits) > 1 {
+			s += "." + digits[1:]
+		}
+		return s + "E" + strconv.Itoa(exp-1)
+	}
+
+	var whole, fraction string
+	if exp <= 0 {
+		whole = "0"
+		fraction = strings.Repeat("0", -exp) + digits
+	} else if len(digits) <= exp {
+		whole = digits + strings.Repeat("0", exp-len(digits))
+	} else {
+		whole, fraction = digits[:exp], digits[exp:]
+	}
+	if len(fraction) < decimals {
+		fraction += strings.Repeat("0", decimals-len(fraction))
+	}
+	return sign + whole + "." + fraction
+}
+
+// delphiGeneral formats f like FloatToStrF(f, ffGeneral, precision, 0). It uses
+// the shortest form, without trailing zeros. Numbers with more than precision
+// digits before the dot or with more than 4 zeros after the dot, i.e. below
+// 0.00001, use E notation like 1.5E20 or 1E-5.
+func delphiGeneral(f *big.Float, precision int) string {
+	digits, exp := decimalDigits(f, precision, math.MaxInt32)
+	if digits == "" {
+		return "0"
+	}
+
+	var s strings.Builder
+	if f.Signbit() {
+		s.WriteByte('-')
+	}
+	if exp > precision || exp < -3 {
+		s.WriteString(digits[:1])
+		if len(digits) > 1 {
+			s.WriteString("." + digits[1:])
+		}
+		s.WriteString("E" + strconv.Itoa(exp-1))
+	} else if exp <= 0 {
+		s.WriteString("0." + strings.Repeat("0", -exp) + digits)
+	} else if len(digits) <= exp {
+		s.WriteString(digits + strings.Repeat("0", exp-len(digits)))
+	} else {
+		s.WriteString(digits[:exp] + "." + digits[exp:])
+	}
+	return s.String()
+}
+
+// decimalDigits rounds |f| to the given number of significant digits, or
+// decimals after the dot, whichever leaves fewer digits. Halves are rounded
+// away from zero. It returns the digits without trailing zeros and the decimal
+// exponent, so that |f| = 0.digits * 10^exp. Zero has no digits.
+func decimalDigits(f *big.Float, precision, decimals int) (digits string, exp int) {
+	r, _ := new(big.Float).Abs(f).Rat(nil)
+	if r.Sign() == 0 {
+		return "", 0
+	}
+
+	// Find exp so that 10^(exp-1) <= r < 10^exp, starting with an estimate
+	// from the binary exponent.
+	exp = int(math.Floor(float64(f.MantExp(nil)-1)*math.Log10(2))) + 1
+	for r.Cmp(pow10(exp)) >= 0 {
+		exp++
+	}
+	for r.Cmp(pow10(exp-1)) < 0 {
+		exp--
+	}
+
+	// decimals can be math.MaxInt32, so exp+decimals would overflow on 32 bit
+	// platforms.
+	n := precision
+	if decimals < n-exp {
+		n = exp + decimals
+	}
+	if n < 0 {
+		return "", 0
+	}
+
+	// Round r * 10^(n-exp) to an integer with n digits.
+	scaled := new(big.Rat).Mul(r, pow10(n-exp))
+	q, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if rem.Lsh(rem, 1).Cmp(scaled.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	digits = q.String()
+	if len(digits) > n {
+		// Rounding carried over into a new digit, e.g. 9.99 became 10.0.
+		exp++
+	}
+	digits = strings.TrimRight(digits, "0")
+	return digits, exp
+}
+
+// pow10 returns 10^n as a big.Rat.
+func pow10(n int) *big.Rat {
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(n))), nil)
+	if n < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+	return new(big.Rat).SetInt(p)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// bigFloat converts f to a big.Float. NaN and +-Infinity become 0 since they
+// are invalid in DFM files.
+func bigFloat(f float64) *big.Float {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		f = 0
+	}
+	return big.NewFloat(f)
+}
+
+// shortestFloat formats f with the fewest digits that parse back to the same
+// float of the given bit size, 32 or 64. NaN and +-Infinity become 0.
+func shortestFloat(f float64, bitSize int) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		f = 0
+	}
+	return delphiExponent(strconv.FormatFloat(f, 'g', -1, bitSize))
+}
+
+// withDot appends ".0" to integral numbers like "1", so they are not parsed as
+// integers.
+func withDot(s string) string {
+	if strings.ContainsAny(s, ".E") {
+		return s
+	}
+	return s + ".0"
+}
@@ -0,0 +1,121 @@
+package dfm_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func printedValue(v dfm.PropertyValue) string {
+	obj := dfm.Object{Type: "T", Properties: []dfm.Property{{Name: "X", Value: v}}}
+	s := obj.String()
+	s = s[strings.Index(s, "X = ")+4:]
+	return s[:strings.Index(s, "\r\n")]
+}
+
+func TestFloatsArePrintedLikeDelphiFloatToStrFFixed16_18(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0.000000000000000000"},
+		{math.Copysign(0, -1), "0.000000000000000000"},
+		{1, "1.000000000000000000"},
+		{-2.5, "-2.500000000000000000"},
+		{0.1, "0.100000000000000000"},
+		// 0.1 + 0.2 is 0.30000000000000004 as float64, Delphi rounds this to 16
+		// significant digits.
+		{0.1 + 0.2, "0.300000000000000000"},
+		{1.0 / 3, "0.333333333333333300"},
+		{2.0 / 3, "0.666666666666666600"},
+		{123.456, "123.456000000000000000"},
+		{39043.36641510417, "39043.366415104170000000"},
+		// Halves are rounded away from zero, 1234567890123456.5 is exact.
+		{1234567890123456.5, "1234567890123457.000000000000000000"},
+		{-1234567890123456.5, "-1234567890123457.000000000000000000"},
+		{9999999999999999, "1E16"},
+		{1e15, "1000000000000000.000000000000000000"},
+		{1e16, "1E16"},
+		{1.5e16, "1.5E16"},
+		{-1e20, "-1E20"},
+		{12345678901234567890, "1.234567890123457E19"},
+		{1.000000040918479e35, "1.000000040918479E35"},
+		{math.MaxFloat64, "1.797693134862316E308"},
+		// Only 18 digits after the dot are written, the rest is rounded.
+		{1e-18, "0.000000000000000001"},
+		{5e-19, "0.000000000000000001"},
+		{4e-19, "0.000000000000000000"},
+		{-1e-20, "0.000000000000000000"},
+		{1.23456789e-10, "0.000000000123456789"},
+		{math.NaN(), "0.000000000000000000"},
+		{math.Inf(-1), "0.000000000000000000"},
+	}
+	for _, test := range tests {
+		check.Eq(t, printedValue(dfm.Float(test.value)), test.want, test.value)
+	}
+}
+
+func TestSingleAndDateArePrintedLikeDelphiFloatToStr(t *testing.T) {
+	tests := []struct {
+		value dfm.PropertyValue
+		want  string
+	}{
+		{dfm.Single(0), "0s"},
+		{dfm.Single(1.5), "1.5s"},
+		{dfm.Single(-0.25), "-0.25s"},
+		{dfm.Single(0.1), "0.100000001490116s"},
+		{dfm.Single(1e-4), "9.99999974737875E-5s"},
+		{dfm.Single(0.0625), "0.0625s"},
+		{dfm.Single(16777216), "16777216s"},
+		{dfm.Single(math.MaxFloat32), "3.40282346638529E38s"},
+		{dfm.Date(0), "0d"},
+		{dfm.Date(45000.5), "45000.5d"},
+		{dfm.Date(-1.25), "-1.25d"},
+		{dfm.Date(0.0001), "0.0001d"},
+		{dfm.Date(0.00001), "1E-5d"},
+		{dfm.Date(123456789012345), "123456789012345d"},
+		{dfm.Date(1234567890123456), "1.23456789012346E15d"},
+		{dfm.Date(1.0 / 3), "0.333333333333333d"},
+		{dfm.Date(0.9999999999999999), "1d"},
+		{dfm.Date(math.NaN()), "0d"},
+	}
+	for _, test := range tests {
+		check.Eq(t, printedValue(test.value), test.want, test.want)
+	}
+}
+
+func TestExtendedKeepsAllDigitsWhenPrinted(t *testing.T) {
+	third, _ := dfm.ParseExtended("0.33333333333333333334")
+	check.Eq(t, printedValue(third), "0.33333333333333333334")
+	check.Eq(t, printedValue(dfm.ExtendedFromFloat64(0.1)), "0.10000000000000000555")
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"1E20", "1E20"},
+		{"-1E20", "-1E20"},
+		{"1E16", "1E16"},
+		{"-1E16", "-1E16"},
+		{"-1E15", "-1000000000000000.000000000000000000"},
+		{"-0.25", "-0.250000000000000000"},
+		// Small numbers use E notation if their digits do not fit into 18
+		// decimals.
+		{"1.5E-20", "1.5E-20"},
+		{"-1.5E-20", "-1.5E-20"},
+		{"1E-30", "1E-30"},
+		{"1E-18", "0.000000000000000001"},
+		{"-1.5E-10", "-0.000000000150000000"},
+	}
+	for _, test := range tests {
+		x, err := dfm.ParseExtended(test.text)
+		check.Eq(t, err, nil)
+		check.Eq(t, printedValue(x), test.want, test.text)
+		back, err := dfm.ParseExtended(test.want)
+		check.Eq(t, err, nil)
+		check.Eq(t, back, x, test.text)
+	}
+}
@@ -0,0 +1,125 @@
+package dfm
+
+import "reflect"
+
+// objectSource is the original code of an Object parsed with
+// ParseOptions.Lossless. The code is split into units which are the object
+// header, each property and the "end" keyword. A unit starts right after the
+// previous unit and reaches to the end of the line of its last token, so it
+// includes its leading white space, any empty lines before it and the line
+// break after it.
+//
+// Together with the units, we keep copies of the parsed values. When printing,
+// a unit is written as is if its values are still the same, otherwise it is
+// printed normally.
+type objectSource struct {
+	header   string
+	kind     ObjectKind
+	name     string
+	typ      string
+	hasIndex bool
+	index    int
+
+	properties []propertySource
+	end        string
+
+	// newline is the line break of the original code. Changed units are
+	// written with it unless PrintOptions.Newline is set. It is empty if the
+	// code has only one line.
+	newline string
+	// trailer is the code after the "end" of a top-level object.
+	trailer string
+	// bom is true if the code of a top-level object started with a UTF-8 byte
+	// order mark.
+	bom bool
+}
+
+type propertySource struct {
+	name  string
+	value PropertyValue
+	code  string
+	// used is set while printing so a unit is not printed twice if there are
+	// multiple properties of the same name.
+	used bool
+}
+
+func (s *objectSource) addProperty(prop Property, code string) {
+	s.properties = append(s.properties, propertySource{
+		name:  prop.Name,
+		value: CloneValue(prop.Value),
+		code:  code,
+	})
+}
+
+// clone returns a copy of s with its own properties so printing the copy does
+// not interfere with printing the original.
+func (s *objectSource) clone() *objectSource {
+	c := *s
+	c.properties = append([]propertySource(nil), s.properties...)
+	return &c
+}
+
+func (s *objectSource) headerUnchanged(o *Object) bool {
+	return s.kind == o.Kind &&
+		s.name == o.Name &&
+		s.typ == o.Type &&
+		s.hasIndex == o.HasIndex &&
+		(!s.hasIndex || s.index == o.Index)
+}
+
+// unchangedProperty returns the original code for the given property if there
+// is an unused unit with the same name and value.
+func (s *objectSource) unchangedProperty(prop Property) (string, bool) {
+	for i := range s.properties {
+		orig := &s.properties[i]
+		if !orig.used && orig.name == prop.Name &&
+			reflect.DeepEqual(orig.value, prop.Value) {
+			orig.used = true
+			return orig.code, true
+		}
+	}
+	return "", false
+}
+
+func (s *objectSource) resetUsed() {
+	for i := range s.properties {
+		s.properties[i].used = false
+	}
+}
+
+// hasNonASCII reports whether any of the original code that might be printed
+// contains non-ASCII characters. Original strings might contain these
+// characters unescaped.
+func (s *objectSource) hasNonASCII() bool {
+	if !isASCII(s.header) || !isASCII(s.end) || !isASCII(s.trailer) {
+		return true
+	}
+	for _, prop := range s.properties {
+		if !isASCII(prop.code) {
+			return true
+		}
+	}
+	return false
+}
+
+// unit returns the code from the end of the last unit to the end of the line
+// of the last token and makes this the start of the next unit. If there is
+// anything other than white space after the last token on its line, the unit
+// ends right after the token.
+func (p *parser) unit() string {
+	code := p.tokens.code
+	end := p.last.endIndex()
+	i := end
+	for i < len(code) && (code[i] == ' ' || code[i] == '\t' || code[i] == '\r') {
+		i++
+	}
+	if i < len(code) && code[i] == '\n' {
+		end = i + 1
+	}
+	if end < p.unitStart {
+		end = p.unitStart
+	}
+	unit := p.tokens.decode(code[p.unitStart:end])
+	p.unitStart = end
+	return unit
+}
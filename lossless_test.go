@@ -0,0 +1,120 @@
+package dfm_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+const losslessCode = "object Form1: TForm1\n" +
+	"  Left=0\n" +
+	"\n" +
+	"  Scale = 1.5\n" +
+	"  Caption = 'abc' + 'def'#65\n" +
+	"   Hint = \n" +
+	"      'first' +\n" +
+	"      'second'\n" +
+	"  DesignSize = (\n" +
+	"    1 2)\n" +
+	"  object Button1: TButton [0]\n" +
+	"    Caption = 'OK'\n" +
+	"  end\n" +
+	"end\n" +
+	"\n"
+
+func parseLossless(t *testing.T, code string) *dfm.Object {
+	t.Helper()
+	obj, err := dfm.ParseBytesWithOptions([]byte(code), dfm.ParseOptions{Lossless: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestLosslessRoundTripIsByteExact(t *testing.T) {
+	obj := parseLossless(t, losslessCode)
+	check.Eq(t, string(obj.Print()), losslessCode)
+}
+
+func TestLosslessPrintingOnlyChangesEditedProperties(t *testing.T) {
+	obj := parseLossless(t, losslessCode)
+	obj.Properties[2].Value = dfm.String("new")
+	button := obj.Properties[5].Value.(*dfm.Object)
+	button.Properties = append(button.Properties, dfm.Property{
+		Name:  "Default",
+		Value: dfm.Bool(true),
+	})
+
+	want := strings.Replace(losslessCode,
+		"  Caption = 'abc' + 'def'#65\n",
+		"  Caption = 'new'\n", 1)
+	want = strings.Replace(want,
+		"    Caption = 'OK'\n",
+		"    Caption = 'OK'\n    Default = True\n", 1)
+	check.Eq(t, string(obj.Print()), want)
+}
+
+func TestLosslessPrintingOfRemovedProperty(t *testing.T) {
+	obj := parseLossless(t, losslessCode)
+	obj.Properties = append(obj.Properties[:3], obj.Properties[4:]...)
+	want := strings.Replace(losslessCode, "   Hint = \n      'first' +\n      'second'\n", "", 1)
+	check.Eq(t, string(obj.Print()), want)
+}
+
+func TestLosslessPrintingOfChangedHeader(t *testing.T) {
+	obj := parseLossless(t, losslessCode)
+	obj.Properties[5].Value.(*dfm.Object).Index = 1
+	want := strings.Replace(losslessCode,
+		"  object Button1: TButton [0]\n",
+		"  object Button1: TButton [1]\n", 1)
+	check.Eq(t, string(obj.Print()), want)
+}
+
+func TestLosslessPrintingDetectsChangesInsideValues(t *testing.T) {
+	obj := parseLossless(t, losslessCode)
+	obj.Properties[4].Value.(dfm.Tuple)[0] = dfm.Int(5)
+	want := strings.Replace(losslessCode,
+		"  DesignSize = (\n    1 2)\n",
+		"  DesignSize = (\n    5\n    2)\n", 1)
+	check.Eq(t, string(obj.Print()), want)
+}
+
+func TestLosslessPrintingKeepsBOM(t *testing.T) {
+	code := string(utf8bom) + "object A: TA\r\nend\r\n"
+	obj := parseLossless(t, code)
+	check.Eq(t, string(obj.Print()), code)
+	check.Eq(t, obj.String(), code[len(utf8bom):])
+}
+
+func TestLosslessRoundTripKeepsBytesLayout(t *testing.T) {
+	code := "object A: TA\n  Data = {\n    01 02\n    03}\n  X = 1\nend\n"
+	obj := parseLossless(t, code)
+	check.Eq(t, obj.Properties[0].Value, dfm.Bytes{1, 2, 3})
+	check.Eq(t, string(obj.Print()), code)
+}
+
+func TestLosslessPrintingUsesOriginalLineBreaks(t *testing.T) {
+	code := "object A: TA\r\n  X = 1\r\nend\r\n"
+	obj := parseLossless(t, code)
+	obj.Properties[0].Value = dfm.Int(2)
+	check.Eq(t, string(obj.Print()), "object A: TA\r\n  X = 2\r\nend\r\n")
+
+	code = "object A: TA\n  X = 1\n  object B: TB\n  end\nend\n"
+	obj = parseLossless(t, code)
+	obj.Properties[0].Value = dfm.Int(2)
+	obj.Properties = append(obj.Properties, dfm.Property{
+		Name:  "C",
+		Value: &dfm.Object{Name: "C", Type: "TC"},
+	})
+	want := "object A: TA\n  X = 2\n  object B: TB\n  end\n  object C: TC\n  end\nend\n"
+	check.Eq(t, string(obj.Print()), want)
+
+	// An explicit Newline is used for the changed parts.
+	var buf strings.Builder
+	err := obj.WriteToWithOptions(&buf, dfm.PrintOptions{Newline: "\r\n"})
+	check.Eq(t, err, nil)
+	want = "object A: TA\n  X = 2\r\n  object B: TB\n  end\n  object C: TC\r\n  end\r\nend\n"
+	check.Eq(t, buf.String(), want)
+}
@@ -0,0 +1,136 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestParseAllReturnsAllTopLevelObjects(t *testing.T) {
+	objs, err := dfm.ParseAll([]byte(`object Button1: TButton
+  Left = 1
+end
+object Label1: TLabel
+  Caption = 'Hi'
+end
+`))
+	check.Eq(t, err, nil)
+	check.Eq(t, objs, []*dfm.Object{
+		{
+			Name: "Button1",
+			Type: "TButton",
+			Properties: []dfm.Property{
+				{Name: "Left", Value: dfm.Int(1)},
+			},
+		},
+		{
+			Name: "Label1",
+			Type: "TLabel",
+			Properties: []dfm.Property{
+				{Name: "Caption", Value: dfm.String("Hi")},
+			},
+		},
+	})
+}
+
+func TestParseAllOfEmptyCodeReturnsNoObjects(t *testing.T) {
+	objs, err := dfm.ParseAll([]byte(" \r\n "))
+	check.Eq(t, err, nil)
+	check.Eq(t, len(objs), 0)
+}
+
+func TestParseAllReportsGarbageBetweenObjects(t *testing.T) {
+	_, err := dfm.ParseAll([]byte("object A: TA\nend\ngarbage\nobject B: TB\nend"))
+	check.Eq(t, err.Error(), `3:1: object start expected (object, inherited or inline) but was "garbage"`)
+}
+
+func TestRecoveringParseAllSkipsGarbageBetweenObjects(t *testing.T) {
+	objs, err := dfm.ParseAllWithOptions(
+		[]byte("object A: TA\nend\ngarbage\nobject B: TB\nend"),
+		dfm.ParseOptions{Recover: true},
+	)
+	check.Eq(t, len(objs), 2)
+	check.Eq(t, objs[0].Name, "A")
+	check.Eq(t, objs[1].Name, "B")
+	check.Eq(t, err.Error(), `3:1: object start expected (object, inherited or inline) but was "garbage"`)
+}
+
+func TestParseAllDecodesBinaryDFMToOneObject(t *testing.T) {
+	objs, err := dfm.ParseAll([]byte("TPF0\x06TPanel\x06Panel1\x00\x00"))
+	check.Eq(t, err, nil)
+	check.Eq(t, objs, []*dfm.Object{{Name: "Panel1", Type: "TPanel"}})
+}
+
+func TestParseFragmentParsesClipboardText(t *testing.T) {
+	objs, err := dfm.ParseFragment(`object Edit1: TEdit
+  Text = 'ä'
+end
+object Edit2: TEdit
+end`)
+	check.Eq(t, err, nil)
+	check.Eq(t, len(objs), 2)
+	check.Eq(t, objs[0].Properties[0].Value, dfm.String("ä"))
+	check.Eq(t, objs[1].Name, "Edit2")
+}
+
+func TestCodeAfterObjectIsIgnoredByDefault(t *testing.T) {
+	obj, err := dfm.ParseString("object A: TA\nend\nobject B: TB\nend")
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Name, "A")
+}
+
+func TestStrictParserRejectsCodeAfterObject(t *testing.T) {
+	_, err := dfm.ParseStringWithOptions(
+		"object A: TA\nend\nobject B: TB\nend",
+		dfm.ParseOptions{Strict: true},
+	)
+	check.Eq(t, err, &dfm.ParseError{
+		Position: dfm.Position{Line: 3, Col: 1, Offset: 17},
+		Token:    "object",
+		Expected: []string{"end of file"},
+		Msg:      `unexpected "object" after the object`,
+	})
+
+	obj, err := dfm.ParseStringWithOptions(
+		"object A: TA\nend\r\n\r\n",
+		dfm.ParseOptions{Strict: true},
+	)
+	check.Eq(t, err, nil)
+	check.Eq(t, obj.Name, "A")
+}
+
+func TestStrictRecoveringParserReportsCodeAfterObject(t *testing.T) {
+	obj, err := dfm.ParseStringWithOptions(
+		"object A: TA\nend\ngarbage",
+		dfm.ParseOptions{Strict: true, Recover: true},
+	)
+	check.Eq(t, obj.Name, "A")
+	check.Eq(t, err.Error(), `3:1: unexpected "garbage" after the object`)
+}
+
+func TestPrintAllWritesObjectsOneAfterAnother(t *testing.T) {
+	code := dfm.PrintAll([]*dfm.Object{
+		{Name: "A", Type: "TA"},
+		{Name: "B", Type: "TB"},
+	})
+	check.Eq(t, string(code), "object A: TA\r\nend\r\nobject B: TB\r\nend\r\n")
+}
+
+func TestPrintAllWritesOneBOMForUnicode(t *testing.T) {
+	code := dfm.PrintAll([]*dfm.Object{
+		{Name: "A", Type: "TA"},
+		{Name: "B", Type: "TB", Properties: []dfm.Property{
+			{Name: "Color", Value: dfm.Identifier("clGrün")},
+		}},
+	})
+	check.Eq(t, string(code), "\xEF\xBB\xBFobject A: TA\r\nend\r\n"+
+		"object B: TB\r\n  Color = clGrün\r\nend\r\n")
+}
+
+func TestLosslessParseAllReprintsCodeAsIs(t *testing.T) {
+	code := "object A: TA\n  Left   =  1\nend\n\nobject B: TB\nend\n"
+	objs, err := dfm.ParseAllWithOptions([]byte(code), dfm.ParseOptions{Lossless: true})
+	check.Eq(t, err, nil)
+	check.Eq(t, string(dfm.PrintAll(objs)), code)
+}
@@ -1,18 +1,38 @@
 package dfm
 
 import (
-	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
 
-func parse(code []rune) (*Object, error) {
-	return newParser(code).parseObject()
+func parse(code []byte, opts ParseOptions) (*Object, error) {
+	return newParser(code, opts).parse()
 }
 
-func newParser(code []rune) *parser {
-	return &parser{tokens: newTokenizer(code)}
+func newParser(code []byte, opts ParseOptions) *parser {
+	p := &parser{
+		tokens:      newTokenizer(code),
+		fileName:    opts.FileName,
+		positions:   opts.Positions,
+		parents:     opts.Parents,
+		recover:     opts.Recover,
+		lossless:    opts.Lossless,
+		strict:      opts.Strict,
+		exactFloats: opts.ExactFloats,
+	}
+	p.trackLast = p.positions != nil || p.recover || p.lossless
+	if p.lossless {
+		p.newline = firstNewline(code)
+	}
+	if p.positions != nil {
+		p.positions.init()
+	}
+	if p.parents != nil && *p.parents == nil {
+		*p.parents = make(Parents)
+	}
+	return p
 }
 
 type parser struct {
@@ -20,6 +40,110 @@ type parser struct {
 	previewToken    token
 	hasPreviewToken bool
 	err             error
+	fileName        string
+	// positions is nil unless the caller asked for them. In that case
+	// objectName is the location of the name of the last object header.
+	positions  *Positions
+	objectName Span
+	// parents is nil unless the caller asked for them.
+	parents *Parents
+	// recover is true if the parser is supposed to continue after errors. All
+	// errors are collected in errors in that case.
+	recover bool
+	errors  ErrorList
+	// strict makes code after the top-level object an error.
+	strict bool
+	// exactFloats makes floating point numbers Extended instead of Float.
+	exactFloats bool
+	// lossless is true if Objects are supposed to keep their original code.
+	// unitStart is the index in the code where the next unit starts, see
+	// objectSource. newline is the first line break in the code.
+	lossless  bool
+	unitStart int
+	newline   string
+	// If trackLast is true, last is the last token returned by nextToken and
+	// beforeLast the one before it. This is only necessary for positions and
+	// error recovery.
+	trackLast  bool
+	last       token
+	beforeLast token
+}
+
+// parse parses the top-level object. In recovery mode, leading garbage is
+// skipped and all errors are returned in an ErrorList.
+func (p *parser) parse() (*Object, error) {
+	obj, err := p.parseTopLevel()
+	if err != nil {
+		return nil, err
+	}
+	if p.recover && obj == nil && len(p.errors) == 0 {
+		p.errorAt(p.peekToken(), objectStarts, "object expected but the code is empty")
+		p.recordError()
+	}
+	if p.strict && !p.peekEOF() {
+		t := p.peekToken()
+		p.errorAt(t, []string{"end of file"}, "unexpected %q after the object", t.text)
+		if !p.recover {
+			return nil, p.err
+		}
+		p.recordError()
+	}
+	p.finish(obj)
+	return obj, p.errors.Err()
+}
+
+// parseAll parses all top-level objects until the end of the code.
+func (p *parser) parseAll() ([]*Object, error) {
+	var objs []*Object
+	for !p.peekEOF() {
+		obj, err := p.parseTopLevel()
+		if err != nil {
+			return nil, err
+		}
+		if obj != nil {
+			objs = append(objs, obj)
+		}
+	}
+	if len(objs) > 0 {
+		p.finish(objs[len(objs)-1])
+	}
+	return objs, p.errors.Err()
+}
+
+func (p *parser) parseTopLevel() (*Object, error) {
+	if p.recover && !p.peekObjectStart() && !p.peekEOF() {
+		start := p.peekToken()
+		p.errorAt(start, objectStarts,
+			"object start expected (object, inherited or inline) but was %q",
+			start.text)
+		p.recordError()
+		for !p.peekObjectStart() && !p.peekEOF() {
+			p.nextToken()
+		}
+	}
+	if p.recover && p.peekEOF() {
+		return nil, nil
+	}
+	return p.parseObject()
+}
+
+// finish stores the code after the last top-level object in lossless mode.
+func (p *parser) finish(last *Object) {
+	if last != nil && last.source != nil {
+		last.source.trailer = p.tokens.decode(p.tokens.code[p.unitStart:])
+	}
+}
+
+// recordError moves the current error to the error list in recovery mode.
+func (p *parser) recordError() {
+	p.errors = append(p.errors, p.err.(*ParseError))
+	p.err = nil
+}
+
+var objectStarts = []string{`"object"`, `"inherited"`, `"inline"`}
+
+func (p *parser) peekObjectStart() bool {
+	return p.peekWord("object") || p.peekWord("inherited") || p.peekWord("inline")
 }
 
 func (p *parser) parseObject() (*Object, error) {
@@ -28,7 +152,89 @@ func (p *parser) parseObject() (*Object, error) {
 	}
 
 	var obj Object
+	var pos ObjectPosition
+	var src *objectSource
+	start := p.peekToken()
+	if p.positions != nil {
+		pos.Header.Start = start.start()
+	}
+
+	p.parseObjectHeader(&obj)
+	if p.err != nil && !p.recover {
+		return nil, p.err
+	}
+
+	var propPos []PropertyPosition
+	if bad, ok := p.recoverFrom(start); ok {
+		obj.Properties = append(obj.Properties, Property{Value: bad})
+		if p.positions != nil {
+			propPos = append(propPos, PropertyPosition{
+				Value: Span{Start: start.start(), End: p.last.end()},
+			})
+		}
+	}
+
+	if p.lossless {
+		src = &objectSource{
+			header:  p.unit(),
+			kind:    obj.Kind,
+			name:    obj.Name,
+			typ:     obj.Type,
+			index:   obj.Index,
+			newline: p.newline,
+		}
+		src.hasIndex = obj.HasIndex
+		for _, prop := range obj.Properties {
+			src.addProperty(prop, "")
+		}
+	}
+
+	if p.positions != nil {
+		pos.Header.End = p.last.end()
+	}
+
+	for p.err == nil {
+		if p.peekEOF() || p.peekWord("end") {
+			end := p.nextToken()
+			if p.positions != nil {
+				pos.End = end.span()
+			}
+			if src != nil {
+				src.end = p.unit()
+			}
+			break
+		}
+		prop, propPosition := p.parseProperty()
+		obj.Properties = append(obj.Properties, prop)
+		if _, isObject := prop.Value.(*Object); src != nil && !isObject {
+			src.addProperty(prop, p.unit())
+		}
+		if p.positions != nil {
+			propPos = append(propPos, propPosition)
+		}
+	}
 
+	obj.source = src
+	if p.parents != nil {
+		p.parents.add(&obj)
+	}
+
+	if p.positions != nil && (p.err == nil || p.recover) {
+		p.positions.Objects[&obj] = pos
+		p.recordProperties(obj.Properties, propPos)
+	}
+
+	if p.recover {
+		// The object might be incomplete but we still want to return it.
+		return &obj, nil
+	}
+
+	return &obj, p.err
+}
+
+// parseObjectHeader parses the object keyword, the name and type and the
+// optional index of an object.
+func (p *parser) parseObjectHeader(obj *Object) {
 	if p.peekWord("object") {
 		p.word("object")
 		obj.Kind = Plain
@@ -39,10 +245,15 @@ func (p *parser) parseObject() (*Object, error) {
 		p.word("inline")
 		obj.Kind = Inline
 	} else {
-		p.err = errors.New("object start expected (object, inherited or inline)")
+		p.errorAt(p.peekToken(), objectStarts,
+			"object start expected (object, inherited or inline) but was %q",
+			p.peekToken().text)
 	}
 
 	nameOrType := p.identifier("object name (or type for anonymous objects)")
+	if p.positions != nil {
+		p.objectName = p.last.span()
+	}
 	if p.peeksAt(':') {
 		obj.Name = nameOrType
 		p.token(':')
@@ -53,49 +264,159 @@ func (p *parser) parseObject() (*Object, error) {
 
 	if p.peeksAt('[') {
 		p.token('[')
+		indexToken := p.peekToken()
 		index := p.parseValue()
-		if i, ok := index.(Int); ok {
+		i, ok := index.(Int)
+		if ok && Int(int(i)) == i {
 			obj.HasIndex = true
 			obj.Index = int(i)
 		} else {
-			p.err = fmt.Errorf("object index must be integer but was %#v", index)
-			return nil, p.err
+			if ok {
+				p.errorAt(indexToken, nil,
+					"object index %d does not fit into an int", i)
+			} else {
+				p.errorAt(indexToken, []string{"integer"},
+					"object index must be integer but was %q", indexToken.text)
+			}
+			if !p.recover {
+				return
+			}
 		}
 		p.token(']')
 	}
-
-	for p.err == nil {
-		if p.peekEOF() || p.peekWord("end") {
-			p.nextToken()
-			break
-		}
-		obj.Properties = append(obj.Properties, p.parseProperty())
-	}
-	return &obj, p.err
 }
 
-func (p *parser) parseProperty() Property {
+func (p *parser) parseProperty() (Property, PropertyPosition) {
 	var prop Property
+	var pos PropertyPosition
+	start := p.peekToken()
 
-	if p.peekWord("object") || p.peekWord("inherited") || p.peekWord("inline") {
+	if p.peekObjectStart() {
 		child, err := p.parseObject()
 		if err != nil {
 			p.err = err
-			return prop
+			return prop, pos
 		}
 		prop.Name = child.Name
 		prop.Value = child
+		if p.positions != nil {
+			childPos := p.positions.Objects[child]
+			pos.Name = p.objectName
+			pos.Value = Span{Start: childPos.Header.Start, End: childPos.End.End}
+		}
 	} else {
-		prop.Name = p.identifier("property name")
-		for p.peekToken().tokenType == '.' {
-			p.nextToken()
-			prop.Name += "." + p.identifier("property name")
+		if p.positions != nil {
+			pos.Name.Start = p.peekToken().start()
+		}
+		prop.Name = p.parsePropertyName()
+		if p.positions != nil {
+			pos.Name.End = p.last.end()
 		}
 		p.token('=')
+		if p.positions != nil {
+			pos.Value.Start = p.peekToken().start()
+		}
 		prop.Value = p.parseValue()
+		if p.positions != nil {
+			pos.Value.End = p.last.end()
+		}
 	}
 
-	return prop
+	if bad, ok := p.recoverFrom(start); ok {
+		prop.Value = bad
+		if p.positions != nil {
+			pos.Value = Span{Start: start.start(), End: p.last.end()}
+		}
+	}
+
+	return prop, pos
+}
+
+// parsePropertyName parses a property name, which can contain dots.
+func (p *parser) parsePropertyName() string {
+	name := p.identifier("property name")
+	for p.peekToken().tokenType == '.' {
+		p.nextToken()
+		name += "." + p.identifier("property name")
+	}
+	return name
+}
+
+// recoverFrom handles an error in recovery mode. It returns false if there was
+// no error or if the parser is not in recovery mode. Otherwise it records the
+// error, skips tokens until parsing can resume and returns the code from start
+// to the last skipped token.
+//
+// Parsing resumes at the keywords end, item, object, inherited and inline, at
+// the end of an item list and at words that start a new line, which are
+// probably property names.
+func (p *parser) recoverFrom(start token) (BadValue, bool) {
+	if p.err == nil || !p.recover {
+		return "", false
+	}
+	err := p.err.(*ParseError)
+	p.errors = append(p.errors, err)
+	p.err = nil
+
+	// If the offending token is a keyword where we would resume anyway, e.g.
+	// the "end" in an unterminated set, we un-read it.
+	if !p.hasPreviewToken && p.last.offset == err.Offset &&
+		p.last.offset != start.offset && isRecoveryKeyword(p.last) {
+		p.previewToken = p.last
+		p.hasPreviewToken = true
+		p.last = p.beforeLast
+	}
+
+	// Make sure that we make progress, otherwise the same error might occur
+	// over and over again.
+	if p.peekToken().offset == start.offset && !p.peekEOF() {
+		p.nextToken()
+	}
+
+	for {
+		t := p.peekToken()
+		if t.tokenType == tokenEOF || t.tokenType == '>' {
+			break
+		}
+		if isRecoveryKeyword(t) {
+			break
+		}
+		if t.tokenType == tokenWord && t.line > p.last.end().Line {
+			break
+		}
+		p.nextToken()
+	}
+	return p.skippedCode(start), true
+}
+
+func isRecoveryKeyword(t token) bool {
+	if t.tokenType != tokenWord {
+		return false
+	}
+	switch strings.ToLower(t.text) {
+	case "end", "item", "object", "inherited", "inline":
+		return true
+	}
+	return false
+}
+
+// skippedCode returns the code from the start token to the end of the last
+// token returned by nextToken.
+func (p *parser) skippedCode(start token) BadValue {
+	end := p.last.endIndex()
+	if end < start.index {
+		return ""
+	}
+	return BadValue(p.tokens.decode(p.tokens.code[start.index:end]))
+}
+
+// recordProperties stores the positions for the given properties. This has to
+// be done after the property slice is complete, otherwise the keys would point
+// into an outdated slice.
+func (p *parser) recordProperties(props []Property, pos []PropertyPosition) {
+	for i := range pos {
+		p.positions.Properties[&props[i]] = pos[i]
+	}
 }
 
 func (p *parser) peekEOF() bool {
@@ -107,6 +428,17 @@ func (p *parser) peekWord(text string) bool {
 	return t.tokenType == tokenWord && strings.ToLower(t.text) == text
 }
 
+// skipValue is like parseValue but does not decode Bytes values.
+func (p *parser) skipValue() {
+	if p.peeksAt('{') {
+		p.nextToken()
+		p.tokens.findClosingBrace()
+		p.token('}')
+	} else {
+		p.parseValue()
+	}
+}
+
 func (p *parser) parseValue() PropertyValue {
 	if p.err != nil {
 		return nil
@@ -124,17 +456,46 @@ func (p *parser) parseValue() PropertyValue {
 		}
 
 		if t.tokenType == tokenInteger {
-			n, err := strconv.Atoi(t.text)
-			if err != nil {
-				p.err = fmt.Errorf("error parsing integer literal: %v", err)
-			}
-			return Int(sign * n)
+			return p.integer(t, sign)
 		} else {
-			n, err := strconv.ParseFloat(t.text, 64)
+			text, suffix := t.text, byte(0)
+			switch last := text[len(text)-1]; last {
+			case 's', 'S', 'c', 'C', 'd', 'D':
+				text, suffix = text[:len(text)-1], last|0x20
+			}
+			if suffix == 0 && p.exactFloats {
+				x, err := ParseExtended(text)
+				if err != nil {
+					p.errorAt(t, nil, "error parsing floating point literal: %v", err)
+				}
+				if sign < 0 {
+					x.SignExp ^= 0x8000
+				}
+				return x
+			}
+			bitSize := 64
+			if suffix == 's' {
+				bitSize = 32
+			}
+			n, err := strconv.ParseFloat(text, bitSize)
 			if err != nil {
-				p.err = fmt.Errorf("error parsing floating point literal: %v", err)
+				p.errorAt(t, nil, "error parsing floating point literal: %v", err)
 			}
-			return Float(float64(sign) * n)
+			n *= float64(sign)
+			switch suffix {
+			case 's':
+				return Single(n)
+			case 'c':
+				// Parse integers exactly, float64 cannot hold all Currency
+				// values.
+				if i, err := strconv.ParseInt(text, 10, 64); err == nil {
+					return Currency(int64(sign) * i)
+				}
+				return Currency(math.Round(n))
+			case 'd':
+				return Date(n)
+			}
+			return Float(n)
 		}
 	case tokenCharacter, tokenString:
 		// String literals can span multiple lines if connected with a '+'.
@@ -151,7 +512,7 @@ func (p *parser) parseValue() PropertyValue {
 			} else if t.tokenType == tokenCharacter {
 				n, err := strconv.Atoi(t.text[1:])
 				if err != nil {
-					p.err = fmt.Errorf("error parsing character literal: %v", err)
+					p.errorAt(t, nil, "error parsing character literal: %v", err)
 					return ""
 				}
 				return string(rune(n))
@@ -188,7 +549,7 @@ func (p *parser) parseValue() PropertyValue {
 		var set Set
 		for {
 			if p.peekEOF() {
-				p.err = errors.New("premature EOF in set")
+				p.errorAt(p.peekToken(), []string{"']'"}, "premature EOF in set")
 				return nil
 			}
 
@@ -209,7 +570,7 @@ func (p *parser) parseValue() PropertyValue {
 		var tuple Tuple
 		for {
 			if p.peekEOF() {
-				p.err = errors.New("premature EOF in tuple")
+				p.errorAt(p.peekToken(), []string{"')'"}, "premature EOF in tuple")
 				return nil
 			}
 
@@ -225,34 +586,27 @@ func (p *parser) parseValue() PropertyValue {
 		code := p.tokens.findClosingBrace()
 		p.token('}')
 
-		// Copy all hex bytes to the start of code.
-		hex := func(r rune) bool {
-			return '0' <= r && r <= '9' ||
-				'A' <= r && r <= 'F' ||
-				'a' <= r && r <= 'f'
-		}
-		n := 0
-		for i := range code {
-			if hex(code[i]) {
-				code[n] = code[i]
-				n++
-			}
-		}
-		code = code[:n]
-
-		// Convert all ASCII hex pairs to bytes.
-		b := make([]byte, len(code)/2)
-		unHex := func(b rune) byte {
-			if '0' <= b && b <= '9' {
-				return byte(b) - '0'
+		// Convert all ASCII hex pairs to bytes, ignoring white space.
+		b := make([]byte, 0, len(code)/2)
+		var high byte
+		haveHigh := false
+		for _, c := range code {
+			var nibble byte
+			if '0' <= c && c <= '9' {
+				nibble = c - '0'
+			} else if 'a' <= c && c <= 'f' {
+				nibble = 10 + c - 'a'
+			} else if 'A' <= c && c <= 'F' {
+				nibble = 10 + c - 'A'
+			} else {
+				continue
 			}
-			if 'a' <= b && b <= 'f' {
-				return 10 + byte(b) - 'a'
+			if haveHigh {
+				b = append(b, high<<4|nibble)
+			} else {
+				high = nibble
 			}
-			return 10 + byte(b) - 'A'
-		}
-		for i := range b {
-			b[i] = unHex(code[i*2])<<4 | unHex(code[i*2+1])
+			haveHigh = !haveHigh
 		}
 
 		return Bytes(b)
@@ -260,17 +614,49 @@ func (p *parser) parseValue() PropertyValue {
 		p.nextToken()
 		var items Items
 		for !p.peeksAt('>') && p.err == nil {
+			if p.peekEOF() {
+				p.errorAt(p.peekToken(), []string{"'>'"}, "premature EOF in item list")
+				return nil
+			}
+			start := p.peekToken()
 			p.word("item")
 			var item []Property
-			for !p.peekWord("end") && p.err == nil {
-				item = append(item, p.parseProperty())
+			var itemPos []PropertyPosition
+			if bad, ok := p.recoverFrom(start); ok {
+				item = append(item, Property{Value: bad})
+				if p.positions != nil {
+					itemPos = append(itemPos, PropertyPosition{})
+				}
 			}
+			for !p.peekWord("end") && !p.peekEOF() && p.err == nil {
+				prop, pos := p.parseProperty()
+				item = append(item, prop)
+				if p.positions != nil {
+					itemPos = append(itemPos, pos)
+				}
+			}
+			start = p.peekToken()
 			p.word("end")
+			if bad, ok := p.recoverFrom(start); ok {
+				item = append(item, Property{Value: bad})
+				if p.positions != nil {
+					itemPos = append(itemPos, PropertyPosition{})
+				}
+			}
 			items = append(items, item)
+			if p.positions != nil && p.err == nil {
+				p.recordProperties(item, itemPos)
+			}
 		}
 		p.nextToken() // Skip '>'.
 		return items
 	case tokenWord:
+		if p.recover && (isRecoveryKeyword(p.peekToken()) || p.peekPropertyOnNewLine()) {
+			// This is most likely a missing value, not an identifier.
+			t := p.peekToken()
+			p.errorAt(t, valueStarts, "property value expected but was %q", t.text)
+			return nil
+		}
 		t := p.nextToken()
 		text := strings.ToLower(t.text)
 		if text == "false" {
@@ -283,7 +669,8 @@ func (p *parser) parseValue() PropertyValue {
 				p.nextToken()
 				t = p.nextToken()
 				if t.tokenType != tokenWord {
-					p.err = fmt.Errorf("another identifier is expected after '.' but was " + t.String())
+					p.errorAt(t, []string{"identifier"},
+						"another identifier is expected after '.' but was %q", t.text)
 					return nil
 				}
 				id += "." + t.text
@@ -291,11 +678,41 @@ func (p *parser) parseValue() PropertyValue {
 			return Identifier(id)
 		}
 	default:
-		p.err = fmt.Errorf("unexpected token for property value: %v", p.nextToken())
+		t := p.nextToken()
+		p.errorAt(t, valueStarts, "unexpected token for property value: %q", t.text)
 		return nil
 	}
 }
 
+// peekPropertyOnNewLine reports whether the next token is a word on a new line
+// that is followed by '=', possibly after more dotted words. This is most likely
+// the name of the next property, following a property without value.
+func (p *parser) peekPropertyOnNewLine() bool {
+	t := p.peekToken()
+	if t.tokenType != tokenWord || t.line <= p.last.end().Line || p.tokens.src != nil {
+		return false
+	}
+	// Look ahead on a copy of the tokenizer, the parser does not see these
+	// tokens.
+	tokens := p.tokens
+	prev := t
+	for {
+		next := tokens.next()
+		for next.tokenType == tokenWhiteSpace {
+			next = tokens.next()
+		}
+		switch {
+		case next.tokenType == '=':
+			return true
+		case prev.tokenType == tokenWord && next.tokenType == '.',
+			prev.tokenType == '.' && next.tokenType == tokenWord:
+			prev = next
+		default:
+			return false
+		}
+	}
+}
+
 func escapeString(s string) string {
 	return strings.Replace(
 		strings.TrimSuffix(strings.TrimPrefix(s, "'"), "'"),
@@ -303,11 +720,36 @@ func escapeString(s string) string {
 	)
 }
 
+// integer converts an integer literal with the given sign to an Int. Positive
+// values that only fit into an unsigned 64 bit integer become UInt64. Larger
+// values are an error.
+func (p *parser) integer(t token, sign int) PropertyValue {
+	n, err := strconv.ParseUint(t.text, 10, 64)
+	if err == nil {
+		if sign > 0 && n <= math.MaxInt64 {
+			return Int(n)
+		}
+		if sign > 0 {
+			return UInt64(n)
+		}
+		if n <= 1<<63 {
+			return Int(-int64(n))
+		}
+	}
+	minus := ""
+	if sign < 0 {
+		minus = "-"
+	}
+	p.errorAt(t, nil, "integer %s%s does not fit into 64 bits", minus, t.text)
+	return nil
+}
+
 func (p *parser) word(text string) {
 	if p.err == nil {
 		t := p.nextToken()
 		if t.tokenType != tokenWord || strings.ToLower(t.text) != text {
-			p.err = fmt.Errorf("%q expected but was %q", text, t.text)
+			expected := []string{strconv.Quote(text)}
+			p.errorAt(t, expected, "%s", expectedButWas(expected, t))
 		}
 	}
 }
@@ -320,7 +762,8 @@ func (p *parser) identifier(desc string) string {
 	if t.tokenType == tokenWord {
 		return t.text
 	}
-	p.err = fmt.Errorf("identifier expected as "+desc+" but was %v", t)
+	p.errorAt(t, []string{"identifier"},
+		"identifier expected as %s but was %q", desc, t.text)
 	return ""
 }
 
@@ -328,7 +771,8 @@ func (p *parser) token(typ tokenType) {
 	if p.err == nil {
 		t := p.nextToken()
 		if t.tokenType != typ {
-			p.err = fmt.Errorf("%v expected but was %v", typ, t)
+			expected := []string{"'" + string(rune(typ)) + "'"}
+			p.errorAt(t, expected, "%s", expectedButWas(expected, t))
 		}
 	}
 }
@@ -355,16 +799,42 @@ func (p *parser) nextToken() token {
 	if p.err != nil {
 		return token{tokenType: tokenEOF}
 	}
+	var t token
 	if p.hasPreviewToken {
 		p.hasPreviewToken = false
-		return p.previewToken
-	}
-	t := p.tokens.next()
-	for t.tokenType == tokenWhiteSpace {
+		t = p.previewToken
+	} else {
 		t = p.tokens.next()
+		for t.tokenType == tokenWhiteSpace {
+			t = p.tokens.next()
+		}
+		if t.tokenType == tokenIllegal {
+			p.errorAt(t, nil, "illegal token %q", t.text)
+		}
 	}
-	if t.tokenType == tokenIllegal {
-		p.err = fmt.Errorf("Illegal token encountered: %q", t.text)
+	if p.trackLast {
+		p.beforeLast = p.last
+		p.last = t
 	}
 	return t
 }
+
+// valueStarts lists what can start a property value, for error messages.
+var valueStarts = []string{
+	"integer", "floating point number", "string", "character",
+	"identifier", "'['", "'('", "'{'", "'<'",
+}
+
+// errorAt sets the parser error to a ParseError at the given token unless an
+// error has already occurred. The message is formatted with fmt.Sprintf.
+func (p *parser) errorAt(t token, expected []string, format string, a ...interface{}) {
+	if p.err == nil {
+		p.err = &ParseError{
+			FileName: p.fileName,
+			Position: t.start(),
+			Token:    t.text,
+			Expected: expected,
+			Msg:      fmt.Sprintf(format, a...),
+		}
+	}
+}
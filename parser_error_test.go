@@ -1,23 +1,31 @@
 package dfm_test
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/gonutz/check"
 	"github.com/gonutz/dfm"
 )
 
-func TestBinaryDFMfilesAreNotImplemented(t *testing.T) {
-	// Binary DFM files start with byte 0xFF. Parsing should stop right there.
+func TestTruncatedBinaryDFM(t *testing.T) {
 	_, err := dfm.ParseBytes([]byte{0xFF})
-	check.Eq(t, err.Error(), "dfm.Parse: binary DFM files are not supported")
+	check.Neq(t, err, nil)
+
+	_, err = dfm.ParseBytes([]byte("TPF0\x06TPanel"))
+	check.Neq(t, err, nil)
+}
+
+func TestUnknownBinaryValueType(t *testing.T) {
+	_, err := dfm.ParseBytes([]byte("TPF0\x01T\x00\x01A\x63"))
+	check.Eq(t, err.Error(), "binary DFM: unknown value type 99 at offset 9")
 }
 
 func TestInvalidIntegerInItems(t *testing.T) {
 	_, err := dfm.ParseString(`object O: TO
   List = <
     item
-	  Value = 123d
+	  Value = 123x
     end>
 end`)
 	check.Neq(t, err, nil)
@@ -32,7 +40,7 @@ end`)
 
 func TestInvalidIntegerInSet(t *testing.T) {
 	_, err := dfm.ParseString(`object O: TO
-	Set = [123d]
+	Set = [123x]
 end`)
 	check.Neq(t, err, nil)
 }
@@ -41,3 +49,163 @@ func TestInvalidObject(t *testing.T) {
 	_, err := dfm.ParseString("Invalid")
 	check.Neq(t, err, nil)
 }
+
+func TestParseErrorHasLocation(t *testing.T) {
+	_, err := dfm.ParseStringWithOptions(`object O: TO
+  Left = 1
+  Top 2
+end`, dfm.ParseOptions{FileName: "Form1.dfm"})
+	check.Eq(t, err, &dfm.ParseError{
+		FileName: "Form1.dfm",
+		Position: dfm.Position{Line: 3, Col: 7, Offset: 30},
+		Token:    "2",
+		Expected: []string{"'='"},
+		Msg:      `'=' expected but was "2"`,
+	})
+	check.Eq(t, err.Error(), `Form1.dfm:3:7: '=' expected but was "2"`)
+}
+
+func TestParseErrorWithoutFileName(t *testing.T) {
+	_, err := dfm.ParseString("object O: TO\n  Set = [a, b")
+	check.Eq(t, err, &dfm.ParseError{
+		Position: dfm.Position{Line: 2, Col: 14, Offset: 26},
+		Expected: []string{"']'"},
+		Msg:      "premature EOF in set",
+	})
+	check.Eq(t, err.Error(), "2:14: premature EOF in set")
+}
+
+func TestParseErrorForMissingObjectStart(t *testing.T) {
+	_, err := dfm.ParseString("  Invalid")
+	parseErr, ok := err.(*dfm.ParseError)
+	check.Eq(t, ok, true)
+	check.Eq(t, parseErr.Position, dfm.Position{Line: 1, Col: 3, Offset: 2})
+	check.Eq(t, parseErr.Token, "Invalid")
+	check.Eq(t, parseErr.Expected, []string{`"object"`, `"inherited"`, `"inline"`})
+}
+
+func TestParseErrorForInvalidValue(t *testing.T) {
+	_, err := dfm.ParseString("object O: TO\n  A = )\nend")
+	parseErr, ok := err.(*dfm.ParseError)
+	check.Eq(t, ok, true)
+	check.Eq(t, parseErr.Position, dfm.Position{Line: 2, Col: 7, Offset: 19})
+	check.Eq(t, parseErr.Token, ")")
+	check.Eq(t, len(parseErr.Expected) > 0, true)
+}
+
+func TestRecoveringParserReportsAllErrors(t *testing.T) {
+	obj, err := dfm.ParseStringWithOptions(`object Form1: TForm1
+  Left = 1
+  Top 2
+  Width = )
+  object Panel1: TPanel
+    Caption = 'ok'
+    Color = [clRed
+  end
+  List = <
+    item
+      A = 1
+      B =
+    end>
+  Height = 5
+end`, dfm.ParseOptions{Recover: true})
+
+	list, ok := err.(dfm.ErrorList)
+	check.Eq(t, ok, true)
+	check.Eq(t, len(list), 4)
+	check.Eq(t, list[0].Line, 3)
+	check.Eq(t, list[1].Line, 4)
+	check.Eq(t, list[2].Line, 8)
+	check.Eq(t, list[3].Line, 13)
+
+	check.Eq(t, obj, &dfm.Object{
+		Name: "Form1",
+		Type: "TForm1",
+		Properties: []dfm.Property{
+			{Name: "Left", Value: dfm.Int(1)},
+			{Name: "Top", Value: dfm.BadValue("Top 2")},
+			{Name: "Width", Value: dfm.BadValue("Width = )")},
+			{Name: "Panel1", Value: &dfm.Object{
+				Name: "Panel1",
+				Type: "TPanel",
+				Properties: []dfm.Property{
+					{Name: "Caption", Value: dfm.String("ok")},
+					{Name: "Color", Value: dfm.BadValue("Color = [clRed")},
+				},
+			}},
+			{Name: "List", Value: dfm.Items{
+				{
+					{Name: "A", Value: dfm.Int(1)},
+					{Name: "B", Value: dfm.BadValue("B =")},
+				},
+			}},
+			{Name: "Height", Value: dfm.Int(5)},
+		},
+	})
+}
+
+func TestRecoveringParserResumesAtPropertyAfterMissingValue(t *testing.T) {
+	obj, err := dfm.ParseStringWithOptions(`object F: TF
+  A = 
+  B = 2
+  C =
+  Font.Height = -11
+  Event =
+    Module.Action
+  D = 4
+end`, dfm.ParseOptions{Recover: true})
+
+	list, ok := err.(dfm.ErrorList)
+	check.Eq(t, ok, true)
+	check.Eq(t, len(list), 2)
+	check.Eq(t, list[0].Error(), `3:3: property value expected but was "B"`)
+	check.Eq(t, list[1].Line, 5)
+
+	// Identifiers on the next line are still values.
+	check.Eq(t, obj.Properties, []dfm.Property{
+		{Name: "A", Value: dfm.BadValue("A =")},
+		{Name: "B", Value: dfm.Int(2)},
+		{Name: "C", Value: dfm.BadValue("C =")},
+		{Name: "Font.Height", Value: dfm.Int(-11)},
+		{Name: "Event", Value: dfm.Identifier("Module.Action")},
+		{Name: "D", Value: dfm.Int(4)},
+	})
+}
+
+func TestRecoveringParserSkipsLeadingGarbage(t *testing.T) {
+	obj, err := dfm.ParseStringWithOptions("garbage\nobject A: TA\nend",
+		dfm.ParseOptions{Recover: true})
+	check.Eq(t, err.Error(), `1:1: object start expected (object, inherited or inline) but was "garbage"`)
+	check.Eq(t, obj, &dfm.Object{Name: "A", Type: "TA"})
+
+	obj, err = dfm.ParseStringWithOptions("garbage", dfm.ParseOptions{Recover: true})
+	check.Neq(t, err, nil)
+	check.Eq(t, obj == nil, true)
+}
+
+func TestRecoveringParserWithoutErrorsReturnsNil(t *testing.T) {
+	obj, err := dfm.ParseStringWithOptions("object A: TA\nend",
+		dfm.ParseOptions{Recover: true})
+	check.Eq(t, err, nil)
+	check.Eq(t, obj, &dfm.Object{Name: "A", Type: "TA"})
+}
+
+func TestObjectIndexMustFitIntoInt(t *testing.T) {
+	obj, err := dfm.ParseString("object A: TA [5000000000]\nend")
+	if strconv.IntSize == 32 {
+		check.Eq(t, err.Error(), `1:15: object index 5000000000 does not fit into an int`)
+	} else {
+		check.Eq(t, err, nil)
+		check.Eq(t, int64(obj.Index), int64(5000000000))
+	}
+
+	var b binaryDFM
+	b.add([]byte("TPF0"), 0xF0|2, 19, int64(5000000000), "TA", "A", 0, 0)
+	obj, err = dfm.ParseBytes(b.Bytes())
+	if strconv.IntSize == 32 {
+		check.Neq(t, err, nil)
+	} else {
+		check.Eq(t, err, nil)
+		check.Eq(t, int64(obj.Index), int64(5000000000))
+	}
+}
@@ -1,6 +1,8 @@
 package dfm_test
 
 import (
+	"fmt"
+	"math"
 	"testing"
 
 	"github.com/gonutz/check"
@@ -229,6 +231,59 @@ func TestParseFloatProperties(t *testing.T) {
 	)
 }
 
+func TestParse64BitIntegers(t *testing.T) {
+	parseProperties(t, `
+  Big = 5000000000
+  Max = 9223372036854775807
+  Min = -9223372036854775808
+  Unsigned = 9223372036854775808
+  MaxUnsigned = 18446744073709551615`,
+		dfm.Property{Name: "Big", Value: dfm.Int(5000000000)},
+		dfm.Property{Name: "Max", Value: dfm.Int(math.MaxInt64)},
+		dfm.Property{Name: "Min", Value: dfm.Int(math.MinInt64)},
+		dfm.Property{Name: "Unsigned", Value: dfm.UInt64(1 << 63)},
+		dfm.Property{Name: "MaxUnsigned", Value: dfm.UInt64(math.MaxUint64)},
+	)
+	obj, _ := dfm.ParseString("object A: TA\n  X = 1\n  Y = 9223372036854775808\nend")
+	check.Eq(t, fmt.Sprintf("%T", obj.Properties[0].Value), "dfm.Int")
+	check.Eq(t, fmt.Sprintf("%T", obj.Properties[1].Value), "dfm.UInt64")
+}
+
+func TestIntegerOverflowIsAnError(t *testing.T) {
+	_, err := dfm.ParseString("object A: TA\n  X = 18446744073709551616\nend")
+	check.Eq(t, err.Error(), "2:7: integer 18446744073709551616 does not fit into 64 bits")
+
+	_, err = dfm.ParseString("object A: TA\n  X = -9223372036854775809\nend")
+	check.Eq(t, err.Error(), "2:8: integer -9223372036854775809 does not fit into 64 bits")
+}
+
+func TestParseTypedFloatProperties(t *testing.T) {
+	parseProperties(t, `
+  Ratio = 1.5s
+  Neg = -0.1S
+  Price = 123400c
+  Rounded = 1.6C
+  Date = 45000.25d
+  Day = 45000D
+  Exp = 1E5s`,
+		dfm.Property{Name: "Ratio", Value: dfm.Single(1.5)},
+		dfm.Property{Name: "Neg", Value: dfm.Single(-0.1)},
+		dfm.Property{Name: "Price", Value: dfm.Currency(123400)},
+		dfm.Property{Name: "Rounded", Value: dfm.Currency(2)},
+		dfm.Property{Name: "Date", Value: dfm.Date(45000.25)},
+		dfm.Property{Name: "Day", Value: dfm.Date(45000)},
+		dfm.Property{Name: "Exp", Value: dfm.Single(1e5)},
+	)
+	obj, _ := dfm.ParseString("object A: TA\n  A = 1.5s\n  B = 1c\n  C = 1d\n  D = 1.0\nend")
+	for i, typ := range []string{"dfm.Single", "dfm.Currency", "dfm.Date", "dfm.Float"} {
+		check.Eq(t, fmt.Sprintf("%T", obj.Properties[i].Value), typ)
+	}
+}
+
+func TestCurrencyIsScaledBy10000(t *testing.T) {
+	check.Eq(t, dfm.Currency(123400).Float64(), 12.34)
+}
+
 func TestParseInheritedObject(t *testing.T) {
 	parseObject(t,
 		`inherited Dialog: TDialog
@@ -0,0 +1,89 @@
+package dfm
+
+import "fmt"
+
+// Position is a location in DFM source code.
+type Position struct {
+	// Line and Col both start at 1. Col counts runes, not bytes.
+	Line, Col int
+	// Offset is the byte offset in the parsed code, starting at 0. If the code
+	// starts with a byte order mark, it is included in the offset.
+	Offset int
+}
+
+// String returns the position as "line:col".
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Span is a range in DFM source code. Start is the position of the first
+// character, End is the position right after the last character.
+type Span struct {
+	Start, End Position
+}
+
+// ObjectPosition holds the locations of an Object's parts.
+type ObjectPosition struct {
+	// Header reaches from the object keyword to the end of the object type or,
+	// if the object has an index, to the closing bracket, e.g.
+	//
+	//     object Button1: TButton [2]
+	Header Span
+	// End is the location of the "end" keyword that closes the object. If the
+	// object is not closed explicitly but by the end of the file, End is empty
+	// and located at the end of the code.
+	End Span
+}
+
+// PropertyPosition holds the locations of a Property's parts.
+type PropertyPosition struct {
+	// Name is the location of the property name, including all dots in it. For
+	// child objects it is the location of the object name or, for anonymous
+	// objects, of the object type.
+	Name Span
+	// Value is the location of the complete value. For strings that span
+	// multiple lines this includes all parts of the string. For child objects
+	// this reaches from the object keyword to the end of its "end" keyword.
+	Value Span
+}
+
+// Positions maps parsed objects and properties to their locations in the code.
+// Pass a pointer to Positions in ParseOptions to have it filled by the parser.
+// Property keys point into the Object.Properties slices and the property lists
+// in Items, so they stay valid as long as these slices are not re-allocated.
+//
+// Positions are only recorded for text DFMs, not for binary ones.
+type Positions struct {
+	Objects    map[*Object]ObjectPosition
+	Properties map[*Property]PropertyPosition
+}
+
+func (p *Positions) init() {
+	if p.Objects == nil {
+		p.Objects = make(map[*Object]ObjectPosition)
+	}
+	if p.Properties == nil {
+		p.Properties = make(map[*Property]PropertyPosition)
+	}
+}
+
+func (t token) start() Position {
+	return Position{Line: t.line, Col: t.col, Offset: t.offset}
+}
+
+func (t token) end() Position {
+	end := Position{Line: t.line, Col: t.col, Offset: t.endOffset}
+	for _, r := range t.text {
+		if r == '\n' {
+			end.Line++
+			end.Col = 1
+		} else {
+			end.Col++
+		}
+	}
+	return end
+}
+
+func (t token) span() Span {
+	return Span{Start: t.start(), End: t.end()}
+}
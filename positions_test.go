@@ -0,0 +1,121 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestParsePositions(t *testing.T) {
+	code := `object Form1: TForm1
+  Font.Height = -11
+  Caption = 'one' +
+    'two'
+  List = <
+    item
+      X = 1
+    end>
+  object TMenuItem [2]
+  end
+end`
+	var pos dfm.Positions
+	obj, err := dfm.ParseStringWithOptions(code, dfm.ParseOptions{Positions: &pos})
+	check.Eq(t, err, nil)
+
+	check.Eq(t, pos.Objects[obj], dfm.ObjectPosition{
+		Header: span(1, 1, 0, 1, 21, 20),
+		End:    span(11, 1, 141, 11, 4, 144),
+	})
+
+	check.Eq(t, pos.Properties[&obj.Properties[0]], dfm.PropertyPosition{
+		Name:  span(2, 3, 23, 2, 14, 34),
+		Value: span(2, 17, 37, 2, 20, 40),
+	})
+	check.Eq(t, pos.Properties[&obj.Properties[1]], dfm.PropertyPosition{
+		Name:  span(3, 3, 43, 3, 10, 50),
+		Value: span(3, 13, 53, 4, 10, 70),
+	})
+
+	items := obj.Properties[2].Value.(dfm.Items)
+	check.Eq(t, pos.Properties[&items[0][0]], dfm.PropertyPosition{
+		Name:  span(7, 7, 97, 7, 8, 98),
+		Value: span(7, 11, 101, 7, 12, 102),
+	})
+
+	child := obj.Properties[3].Value.(*dfm.Object)
+	check.Eq(t, pos.Objects[child], dfm.ObjectPosition{
+		Header: span(9, 3, 114, 9, 23, 134),
+		End:    span(10, 3, 137, 10, 6, 140),
+	})
+	check.Eq(t, pos.Properties[&obj.Properties[3]], dfm.PropertyPosition{
+		Name:  span(9, 10, 121, 9, 19, 130),
+		Value: span(9, 3, 114, 10, 6, 140),
+	})
+}
+
+func TestPositionOffsetsAreByteOffsets(t *testing.T) {
+	// The BOM and the two-byte UTF-8 ä count as bytes but not as columns.
+	code := append(utf8bom, []byte("object ä A=1 end")...)
+	var pos dfm.Positions
+	obj, err := dfm.ParseBytesWithOptions(code, dfm.ParseOptions{Positions: &pos})
+	check.Eq(t, err, nil)
+	check.Eq(t, pos.Properties[&obj.Properties[0]].Name, span(1, 10, 13, 1, 11, 14))
+
+	// In Windows ANSI, the ä is a single byte.
+	code = []byte("object \xE4 A=1 end")
+	pos = dfm.Positions{}
+	obj, err = dfm.ParseBytesWithOptions(code, dfm.ParseOptions{Positions: &pos})
+	check.Eq(t, err, nil)
+	check.Eq(t, pos.Properties[&obj.Properties[0]].Name, span(1, 10, 9, 1, 11, 10))
+}
+
+func TestObjectClosedByEOFHasEmptyEndPosition(t *testing.T) {
+	var pos dfm.Positions
+	obj, err := dfm.ParseStringWithOptions("object A: T", dfm.ParseOptions{Positions: &pos})
+	check.Eq(t, err, nil)
+	check.Eq(t, pos.Objects[obj].End, span(1, 12, 11, 1, 12, 11))
+}
+
+func TestPositionsAreRecordedWhenRecovering(t *testing.T) {
+	code := `object Form1: TForm1
+  Left = 1
+  object Panel1: TPanel
+    Caption = 'ok'
+  end
+end`
+	var pos dfm.Positions
+	obj, err := dfm.ParseStringWithOptions(code,
+		dfm.ParseOptions{Positions: &pos, Recover: true})
+	check.Eq(t, err, nil)
+	check.Eq(t, len(pos.Objects), 2)
+	check.Eq(t, len(pos.Properties), 3)
+	check.Eq(t, pos.Properties[&obj.Properties[0]].Name, span(2, 3, 23, 2, 7, 27))
+
+	code = `object Form1: TForm1
+  Top 2
+  object Panel1: TPanel
+    Color = [clRed
+  end
+  Left = 1`
+	pos = dfm.Positions{}
+	obj, err = dfm.ParseStringWithOptions(code,
+		dfm.ParseOptions{Positions: &pos, Recover: true})
+	check.Neq(t, err, nil)
+	check.Eq(t, len(pos.Objects), 2)
+	check.Eq(t, len(pos.Properties), 4)
+	check.Eq(t, pos.Properties[&obj.Properties[0]].Value, span(2, 3, 23, 2, 8, 28))
+	check.Eq(t, pos.Properties[&obj.Properties[2]].Name, span(6, 3, 80, 6, 7, 84))
+	panel := obj.Properties[1].Value.(*dfm.Object)
+	check.Eq(t, pos.Objects[panel].Header, span(3, 3, 31, 3, 24, 52))
+	check.Eq(t, pos.Properties[&panel.Properties[0]].Value.Start, dfm.Position{
+		Line: 4, Col: 5, Offset: 57,
+	})
+}
+
+func span(line1, col1, offset1, line2, col2, offset2 int) dfm.Span {
+	return dfm.Span{
+		Start: dfm.Position{Line: line1, Col: col1, Offset: offset1},
+		End:   dfm.Position{Line: line2, Col: col2, Offset: offset2},
+	}
+}
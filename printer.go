@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"math"
 	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -30,55 +30,247 @@ func (o Object) Print() []byte {
 	return buf.Bytes()
 }
 
+// PrintOptions change how Objects are printed. The zero value is the default
+// used by Print and WriteTo, which formats DFMs like RAD Studio XE4 does. Zero
+// fields mean the default value, see DefaultPrintOptions.
+type PrintOptions struct {
+	// Encoding of the output. By default the code is pure ASCII or, if the
+	// Objects contain unicode characters, UTF-8 with a byte order mark. ANSI
+	// fails if the Objects contain characters that do not exist in CodePage.
+	// See BOM for when byte order marks are written.
+	Encoding Encoding
+	// CodePage is the Windows code page for the ANSI Encoding. The default 0
+	// means Windows-1252. See ParseOptions.CodePage for the supported code
+	// pages.
+	CodePage int
+	// RawStrings writes non-ASCII characters in strings as they are, instead
+	// of as #nnn escapes. For the ANSI Encoding, characters that do not exist
+	// in the code page are still escaped. Control characters are always
+	// escaped.
+	RawStrings bool
+	// BOM determines whether a byte order mark is written. ANSI code never
+	// has one.
+	BOM BOMPolicy
+	// Newline is written at the end of lines. The default is "\r\n", like
+	// Delphi writes it. Unchanged parts of Objects parsed with
+	// ParseOptions.Lossless keep their original line breaks, changed parts
+	// use the original line break by default as well.
+	Newline string
+	// Indent is written once per nesting level at the start of lines, the
+	// default is two spaces.
+	Indent string
+	// StringWrapWidth is the number of characters after which strings are
+	// split into multiple lines, joined with +. The default is 64. Negative
+	// values never split strings.
+	StringWrapWidth int
+	// BytesPerLine is the number of bytes per line of Bytes values, which are
+	// written as hexadecimal numbers. The default is 32. Negative values write
+	// all bytes in one line.
+	BytesPerLine int
+	// FloatStyle determines how floating point numbers are written.
+	FloatStyle FloatStyle
+	// SkipExplicitProperties omits the properties ExplicitLeft, ExplicitTop,
+	// ExplicitWidth and ExplicitHeight, which Delphi 2006 and later write for
+	// aligned and anchored controls and which older versions do not know.
+	SkipExplicitProperties bool
+}
+
+// DefaultPrintOptions returns the options that Print and WriteTo use, with all
+// fields set explicitly.
+func DefaultPrintOptions() PrintOptions {
+	return PrintOptions{
+		Newline:         "\r\n",
+		Indent:          "  ",
+		StringWrapWidth: 64,
+		BytesPerLine:    32,
+	}
+}
+
+// withDefaults returns the options with all zero fields set to their default.
+func (opts PrintOptions) withDefaults() PrintOptions {
+	def := DefaultPrintOptions()
+	if opts.Newline == "" {
+		opts.Newline = def.Newline
+	}
+	if opts.Indent == "" {
+		opts.Indent = def.Indent
+	}
+	if opts.StringWrapWidth == 0 {
+		opts.StringWrapWidth = def.StringWrapWidth
+	}
+	if opts.BytesPerLine == 0 {
+		opts.BytesPerLine = def.BytesPerLine
+	}
+	return opts
+}
+
+// FloatStyle determines how the printer writes floating point numbers.
+type FloatStyle int
+
+const (
+	// DelphiFloats is the default. Floats are written like Delphi writes
+	// them, Float with 18 digits after the dot, e.g. 0.100000000000000000, and
+	// Single and Date values with 15 significant digits. See the Float type.
+	DelphiFloats FloatStyle = iota
+	// ShortestFloats writes the shortest number that is parsed back to the
+	// same value, e.g. 0.1 for Float, 0.1s for Single and 1E20 for Float(1e20).
+	// Floats always contain a dot or an exponent so they are not parsed as
+	// integers.
+	ShortestFloats
+)
+
+// BOMPolicy determines when the printer writes a byte order mark.
+type BOMPolicy int
+
+const (
+	// AutoBOM is the default. It writes a byte order mark for UTF-8 and UTF-16
+	// Encodings and for AutoEncoding if the code is not pure ASCII.
+	AutoBOM BOMPolicy = iota
+	// AlwaysBOM writes a byte order mark even for pure ASCII code with
+	// AutoEncoding, which is then UTF-8.
+	AlwaysBOM
+	// NeverBOM writes no byte order mark.
+	NeverBOM
+)
+
+// PrintWithOptions is like Print but uses the given options. It returns an
+// error if the Object cannot be encoded as requested.
+func (o Object) PrintWithOptions(opts PrintOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	err := o.WriteToWithOptions(&buf, opts)
+	return buf.Bytes(), err
+}
+
 // Write prints the text representation of the Object as DFM code to the given
 // io.Writer. Float values NaN and +-Infinity are printed as 0 since they are
 // invalid in DFM files. If the Object contains unicode characters the text will
 // be encoded as UTF-8 and start with the UTF-8 byte order mark.
+//
+// Objects parsed with ParseOptions.Lossless keep the original code of all
+// unchanged parts. In that case the BOM is written if the original code had it
+// or if the output contains unicode characters.
 func (o *Object) WriteTo(w io.Writer) error {
-	p := printer{}
-	if !onlyASCII(o) {
-		p.Write(utf8bom)
+	return o.WriteToWithOptions(w, PrintOptions{})
+}
+
+// WriteToWithOptions is like WriteTo but uses the given options.
+func (o *Object) WriteToWithOptions(w io.Writer, opts PrintOptions) error {
+	return writeObjects(w, []*Object{o}, opts)
+}
+
+// PrintAll returns the text representation of all the given objects, one after
+// the other, like the Delphi form designer puts them on the clipboard. It is
+// the counterpart of ParseAll. The code starts with a UTF-8 byte order mark if
+// any of the objects contains unicode characters.
+func PrintAll(objects []*Object) []byte {
+	var buf bytes.Buffer
+	WriteAllTo(&buf, objects)
+	return buf.Bytes()
+}
+
+// WriteAllTo writes the text representation of all the given objects to the
+// given io.Writer. See PrintAll.
+func WriteAllTo(w io.Writer, objects []*Object) error {
+	return writeObjects(w, objects, PrintOptions{})
+}
+
+func writeObjects(w io.Writer, objects []*Object, opts PrintOptions) error {
+	cp, err := lookupCodePage(opts.CodePage)
+	if err != nil {
+		return err
+	}
+	p := newPrinter(opts)
+	if opts.Encoding == ANSI {
+		p.cp = cp
+	}
+	bom := false
+	for _, o := range objects {
+		p.object(o)
+		if o.source != nil {
+			p.WriteString(o.source.trailer)
+		}
+		bom = bom || !onlyASCII(o) || keepsBOM(o)
+	}
+	switch {
+	case opts.BOM == AlwaysBOM:
+		bom = true
+	case opts.BOM == NeverBOM:
+		bom = false
+	case opts.Encoding != AutoEncoding:
+		// UTF-8 and UTF-16 always have a byte order mark by default, ANSI
+		// ignores it.
+		bom = true
+	case opts.RawStrings:
+		// Strings are ASCII unless they are written raw.
+		bom = bom || !allASCII(p.Bytes())
+	}
+	code, err := encode(p.Bytes(), opts.Encoding, cp, bom)
+	if err != nil {
+		return err
 	}
-	p.object(o)
-	_, err := w.Write(p.Bytes())
+	_, err = w.Write(code)
 	return err
 }
 
-func onlyASCII(value PropertyValue) bool {
-	switch v := value.(type) {
-	case *Object:
-		if !(isASCII(v.Name) && isASCII(v.Type)) {
-			return false
+// keepsBOM reports whether the original code of an Object parsed in lossless
+// mode requires a UTF-8 byte order mark.
+func keepsBOM(o *Object) bool {
+	if o.source == nil {
+		return false
+	}
+	if o.source.bom {
+		return true
+	}
+	nonASCII := false
+	var check func(o *Object)
+	check = func(o *Object) {
+		if o.source != nil && o.source.hasNonASCII() {
+			nonASCII = true
 		}
-		for i := range v.Properties {
-			if !(isASCII(v.Properties[i].Name) && onlyASCII(v.Properties[i].Value)) {
-				return false
+		for _, prop := range o.Properties {
+			if child, ok := prop.Value.(*Object); ok {
+				check(child)
 			}
 		}
-	case Identifier:
-		return isASCII(string(v))
-	case Set:
-		for i := range v {
-			if !onlyASCII(v[i]) {
-				return false
-			}
+	}
+	check(o)
+	return nonASCII
+}
+
+func onlyASCII(value PropertyValue) bool {
+	ascii := true
+	Inspect(value, func(c *Cursor) bool {
+		if c == nil || !ascii {
+			return false
 		}
-	case Tuple:
-		for i := range v {
-			if !onlyASCII(v[i]) {
-				return false
-			}
+		if c.Property() != nil && !isASCII(c.Property().Name) {
+			ascii = false
 		}
-	case Items:
-		for i := range v {
-			for j := range v[i] {
-				if !(isASCII(v[i][j].Name) && onlyASCII(v[i][j].Value)) {
-					return false
-				}
-			}
+		switch v := c.Value().(type) {
+		case *Object:
+			ascii = ascii && isASCII(v.Name) && isASCII(v.Type)
+		case Identifier:
+			ascii = ascii && isASCII(string(v))
+		case BadValue:
+			ascii = ascii && isASCII(string(v))
 		}
+		return ascii
+	})
+	return ascii
+}
+
+// delphiExponent converts the exponent of a number formatted with Go's 'g'
+// format to Delphi's style, e.g. 1e+20 becomes 1E20 and 1e-05 becomes 1E-5.
+func delphiExponent(s string) string {
+	e := strings.IndexByte(s, 'e')
+	if e == -1 {
+		return s
 	}
-	return true
+	exp := strings.TrimLeft(s[e+2:], "0")
+	if s[e+1] == '-' {
+		exp = "-" + exp
+	}
+	return s[:e] + "E" + exp
 }
 
 func isASCII(s string) bool {
@@ -93,6 +285,35 @@ func isASCII(s string) bool {
 type printer struct {
 	bytes.Buffer
 	indent string
+	opts   PrintOptions
+	// cp is the code page of the output. With PrintOptions.RawStrings only
+	// characters that exist in cp are written as they are. cp is nil if all
+	// characters can be written.
+	cp *codePage
+	// sourceNewline is the line break of the lossless Object that is being
+	// printed. It is used unless the options set a Newline explicitly.
+	sourceNewline string
+	newlineSet    bool
+}
+
+func newPrinter(opts PrintOptions) printer {
+	return printer{opts: opts.withDefaults(), newlineSet: opts.Newline != ""}
+}
+
+func (p *printer) newline() string {
+	if !p.newlineSet && p.sourceNewline != "" {
+		return p.sourceNewline
+	}
+	return p.opts.Newline
+}
+
+// writesRaw reports whether the printer writes r into a string as it is.
+func (p *printer) writesRaw(r rune) bool {
+	if 32 <= r && r < 127 {
+		return r != '\''
+	}
+	return p.opts.RawStrings && r >= 0xA0 && r != utf8.RuneError && unicode.IsPrint(r) &&
+		(p.cp == nil || p.cp.canEncode(r))
 }
 
 func (p *printer) write(s ...string) {
@@ -102,79 +323,140 @@ func (p *printer) write(s ...string) {
 }
 
 func (p *printer) incIndent() {
-	p.indent += "  "
+	p.indent += p.opts.Indent
 }
 
 func (p *printer) decIndent() {
-	p.indent = p.indent[:len(p.indent)-2]
+	p.indent = p.indent[:len(p.indent)-len(p.opts.Indent)]
 }
 
 func (p *printer) object(o *Object) {
-	if o.Name == "" {
-		// Anonymous object.
-		p.write(p.indent, o.Kind.String(), " ", o.Type)
-	} else {
-		p.write(p.indent, o.Kind.String(), " ", o.Name, ": ", o.Type)
+	if o.source != nil {
+		p.objectWithSource(o)
+		return
 	}
-	if o.HasIndex {
-		p.write(" [", strconv.Itoa(o.Index), "]")
+	p.objectHeader(o)
+	p.incIndent()
+	for _, prop := range o.Properties {
+		if obj, ok := prop.Value.(*Object); ok {
+			p.object(obj)
+		} else if !p.skips(prop) {
+			p.property(prop)
+		}
+	}
+	p.decIndent()
+	p.write(p.indent, "end", p.newline())
+}
+
+// objectWithSource prints an Object that was parsed in lossless mode. All
+// unchanged parts are written as they were in the original code.
+func (p *printer) objectWithSource(o *Object) {
+	src := o.source
+	src.resetUsed()
+	if src.newline != "" {
+		defer func(newline string) { p.sourceNewline = newline }(p.sourceNewline)
+		p.sourceNewline = src.newline
+	}
+
+	if src.headerUnchanged(o) {
+		p.WriteString(src.header)
+	} else {
+		p.objectHeader(o)
 	}
-	p.WriteString("\r\n")
 	p.incIndent()
 	for _, prop := range o.Properties {
 		if obj, ok := prop.Value.(*Object); ok {
 			p.object(obj)
+		} else if p.skips(prop) {
+			continue
+		} else if code, ok := src.unchangedProperty(prop); ok {
+			p.WriteString(code)
 		} else {
 			p.property(prop)
 		}
 	}
 	p.decIndent()
-	p.write(p.indent, "end\r\n")
+	p.WriteString(src.end)
+}
+
+// skips reports whether the property is omitted from the output.
+func (p *printer) skips(prop Property) bool {
+	if !p.opts.SkipExplicitProperties {
+		return false
+	}
+	switch prop.Name {
+	case "ExplicitLeft", "ExplicitTop", "ExplicitWidth", "ExplicitHeight":
+		return true
+	}
+	return false
+}
+
+func (p *printer) objectHeader(o *Object) {
+	if o.Name == "" {
+		// Anonymous object.
+		p.write(p.indent, o.Kind.String(), " ", o.Type)
+	} else {
+		p.write(p.indent, o.Kind.String(), " ", o.Name, ": ", o.Type)
+	}
+	if o.HasIndex {
+		p.write(" [", strconv.Itoa(o.Index), "]")
+	}
+	p.WriteString(p.newline())
 }
 
 func (p *printer) property(prop Property) {
+	if bad, ok := prop.Value.(BadValue); ok {
+		p.write(p.indent, string(bad), p.newline())
+		return
+	}
 	p.write(p.indent, prop.Name, " = ")
 	p.propertyValue(prop.Value)
-	p.WriteString("\r\n")
+	p.WriteString(p.newline())
 }
 
 func (p *printer) propertyValue(value PropertyValue) {
 	switch v := value.(type) {
 	case Int:
-		p.WriteString(strconv.Itoa(int(v)))
+		p.WriteString(strconv.FormatInt(int64(v), 10))
+	case Int64:
+		p.WriteString(strconv.FormatInt(int64(v), 10))
+	case UInt64:
+		p.WriteString(strconv.FormatUint(uint64(v), 10))
 	case Float:
-		f := float64(v)
-		if math.IsNaN(f) || math.IsInf(f, 0) {
-			f = 0
+		if p.opts.FloatStyle == ShortestFloats {
+			p.WriteString(withDot(shortestFloat(float64(v), 64)))
+		} else {
+			p.WriteString(delphiFixed(bigFloat(float64(v)), 16))
 		}
-		// Delphi prints floating point numbers with 18 digits after the dot,
-		// always. When converting to a string it uses the "best" visual style,
-		// this is the -1 for strconv.FormatFloat in Go, and it just fills it
-		// with 0s. To be as close to Delphi as possible, we do the same here.
-		//
-		// Also Delphi uses E notation for numbers above 1e+16. Below that, even
-		// for numbers below 1e-16, it uses digits only.
-		var s string
-		if f >= 1e+16 {
-			s = strconv.FormatFloat(f, 'e', -1, 64)
+	case Extended:
+		if !v.IsFinite() {
+			p.propertyValue(Float(0))
+			break
+		}
+		if p.opts.FloatStyle == ShortestFloats {
+			p.WriteString(withDot(v.String()))
+			break
+		}
+		// Extended values are laid out like Float but keep all the digits
+		// necessary for 64 bits of mantissa, even where Delphi would round
+		// them.
+		p.WriteString(exactFixed(v.big()))
+	case Single:
+		if p.opts.FloatStyle == ShortestFloats {
+			p.WriteString(shortestFloat(float64(v), 32) + "s")
 		} else {
-			s = strconv.FormatFloat(f, 'f', -1, 64)
+			p.WriteString(delphiGeneral(bigFloat(float64(v)), 15) + "s")
 		}
-		if strings.Contains(s, "e") {
-			s = strings.Replace(s, "e+", "E", 1)
-			s = strings.Replace(s, "e-", "E-", 1)
+	case Currency:
+		// Delphi writes Currency values with 15 significant digits, we write
+		// all digits so larger values do not change when parsed again.
+		p.WriteString(strconv.FormatInt(int64(v), 10) + "c")
+	case Date:
+		if p.opts.FloatStyle == ShortestFloats {
+			p.WriteString(shortestFloat(float64(v), 64) + "d")
 		} else {
-			dot := strings.Index(s, ".")
-			if dot == -1 {
-				s += ".000000000000000000"
-			} else {
-				zeros := 18 - (len(s) - 1 - dot)
-				for i := 0; i < zeros; i++ {
-					s += "0"
-				}
-			}
+			p.WriteString(delphiGeneral(bigFloat(float64(v)), 15) + "d")
 		}
-		p.WriteString(s)
 	case Bool:
 		if v {
 			p.WriteString("True")
@@ -189,12 +471,12 @@ func (p *printer) propertyValue(value PropertyValue) {
 			p.WriteString("''")
 		}
 
-		const maxLineLen = 64
+		maxLineLen := p.opts.StringWrapWidth
 		lineLen := 0
-		oneLine := utf8.RuneCountInString(s) <= maxLineLen
+		oneLine := maxLineLen < 0 || utf8.RuneCountInString(s) <= maxLineLen
 		if !oneLine {
 			p.incIndent()
-			p.write("\r\n", p.indent)
+			p.write(p.newline(), p.indent)
 		}
 		inString := false
 		beInString := func(in bool) {
@@ -205,15 +487,15 @@ func (p *printer) propertyValue(value PropertyValue) {
 		}
 
 		for _, r := range s {
-			if lineLen >= maxLineLen {
+			if lineLen >= maxLineLen && !oneLine {
 				beInString(false)
-				p.write(" +\r\n", p.indent)
+				p.write(" +", p.newline(), p.indent)
 				lineLen = 0
 			}
 
-			if 32 <= r && r < 127 && r != '\'' {
+			if p.writesRaw(r) {
 				beInString(true)
-				p.WriteRune(r) // Printable ASCII character.
+				p.WriteRune(r) // Printable character.
 			} else {
 				beInString(false)
 				// Unicode character as #<number>.
@@ -229,6 +511,9 @@ func (p *printer) propertyValue(value PropertyValue) {
 		}
 	case Identifier:
 		p.WriteString(string(v))
+	case BadValue:
+		// This is the original code of the damaged property.
+		p.WriteString(string(v))
 	case Set:
 		p.WriteByte('[')
 		for i := range v {
@@ -242,20 +527,20 @@ func (p *printer) propertyValue(value PropertyValue) {
 		p.WriteByte('(')
 		p.incIndent()
 		for i := range v {
-			p.write("\r\n", p.indent)
+			p.write(p.newline(), p.indent)
 			p.propertyValue(v[i])
 		}
 		p.WriteByte(')')
 		p.decIndent()
 	case Bytes:
 		p.incIndent()
-		p.write("{\r\n", p.indent)
+		p.write("{", p.newline(), p.indent)
 		hexNibble := []byte("0123456789ABCDEF")
-		const maxLineLen = 31
+		maxLineLen := p.opts.BytesPerLine
 		lineLen := 0
 		for _, b := range v {
-			if lineLen > maxLineLen {
-				p.write("\r\n", p.indent)
+			if lineLen >= maxLineLen && maxLineLen > 0 {
+				p.write(p.newline(), p.indent)
 				lineLen = 0
 			}
 			p.WriteByte(hexNibble[b&0xF0>>4])
@@ -268,7 +553,7 @@ func (p *printer) propertyValue(value PropertyValue) {
 		p.WriteByte('<')
 		p.incIndent()
 		for _, properties := range v {
-			p.write("\r\n", p.indent, "item\r\n")
+			p.write(p.newline(), p.indent, "item", p.newline())
 			p.incIndent()
 			for _, prop := range properties {
 				p.property(prop)
@@ -2,6 +2,7 @@ package dfm_test
 
 import (
 	"bytes"
+	"fmt"
 	"math"
 	"strings"
 	"testing"
@@ -256,3 +257,145 @@ end
 		)
 	}
 }
+
+func TestBadValuesArePrintedAsIs(t *testing.T) {
+	obj := prop("Top", dfm.BadValue("Top 2"))
+	check.Eq(t, obj.String(), "object \r\n  Top 2\r\nend\r\n")
+}
+
+func TestPrint64BitIntegers(t *testing.T) {
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{
+		{Name: "Min", Value: dfm.Int(math.MinInt64)},
+		{Name: "Forced", Value: dfm.Int64(1)},
+		{Name: "Max", Value: dfm.UInt64(math.MaxUint64)},
+	}}
+	check.Eq(t, obj.String(), "object TA\r\n"+
+		"  Min = -9223372036854775808\r\n"+
+		"  Forced = 1\r\n"+
+		"  Max = 18446744073709551615\r\n"+
+		"end\r\n")
+}
+
+func TestPrintTypedFloats(t *testing.T) {
+	obj := dfm.Object{Name: "A", Type: "TA", Properties: []dfm.Property{
+		{Name: "Ratio", Value: dfm.Single(1.5)},
+		{Name: "Tenth", Value: dfm.Single(0.1)},
+		{Name: "Big", Value: dfm.Single(1e20)},
+		{Name: "Small", Value: dfm.Single(-1e-5)},
+		{Name: "Price", Value: dfm.Currency(123400)},
+		{Name: "Debt", Value: dfm.Currency(-5)},
+		{Name: "Date", Value: dfm.Date(45000.25)},
+		{Name: "Day", Value: dfm.Date(45000)},
+	}}
+	check.Eq(t, obj.String(), strings.Replace(`object A: TA
+  Ratio = 1.5s
+  Tenth = 0.100000001490116s
+  Big = 1.00000002004088E20s
+  Small = -9.99999974737875E-6s
+  Price = 123400c
+  Debt = -5c
+  Date = 45000.25d
+  Day = 45000d
+end
+`, "\n", "\r\n", -1))
+}
+
+func TestTypedFloatsRoundTrip(t *testing.T) {
+	values := []dfm.PropertyValue{
+		dfm.Single(0.1),
+		dfm.Single(3.4028235e38),
+		dfm.Single(1.17549435e-38),
+		dfm.Currency(-922337203685477580),
+		dfm.Date(-693593.999988426),
+	}
+	for _, v := range values {
+		obj := dfm.Object{Type: "T", Properties: []dfm.Property{{Name: "V", Value: v}}}
+		parsed, err := dfm.ParseString(obj.String())
+		check.Eq(t, err, nil)
+		check.Eq(t, parsed.Properties[0].Value, v)
+		check.Eq(t, fmt.Sprintf("%T", parsed.Properties[0].Value), fmt.Sprintf("%T", v))
+	}
+}
+
+func TestDefaultPrintOptionsPrintLikePrint(t *testing.T) {
+	obj := dfm.Object{Name: "A", Type: "TA", Properties: []dfm.Property{
+		{Name: "S", Value: dfm.String(strings.Repeat("ä", 100))},
+		{Name: "B", Value: dfm.Bytes(make([]byte, 100))},
+		{Name: "F", Value: dfm.Float(0.1)},
+	}}
+	have, err := obj.PrintWithOptions(dfm.DefaultPrintOptions())
+	check.Eq(t, err, nil)
+	check.Eq(t, have, obj.Print())
+}
+
+func TestPrintIndentWrapWidthAndBytesPerLine(t *testing.T) {
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{
+		{Name: "S", Value: dfm.String("abcdefgh")},
+		{Name: "B", Value: dfm.Bytes{1, 2, 3, 4, 5}},
+		{Value: &dfm.Object{Type: "TB", Properties: []dfm.Property{
+			{Name: "X", Value: dfm.Int(1)},
+		}}},
+	}}
+	have, err := obj.PrintWithOptions(dfm.PrintOptions{
+		Indent:          "\t",
+		Newline:         "\n",
+		StringWrapWidth: 3,
+		BytesPerLine:    2,
+	})
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "object TA\n"+
+		"\tS = \n"+
+		"\t\t'abc' +\n"+
+		"\t\t'def' +\n"+
+		"\t\t'gh'\n"+
+		"\tB = {\n"+
+		"\t\t0102\n"+
+		"\t\t0304\n"+
+		"\t\t05}\n"+
+		"\tobject TB\n"+
+		"\t\tX = 1\n"+
+		"\tend\n"+
+		"end\n")
+
+	have, err = obj.PrintWithOptions(dfm.PrintOptions{
+		StringWrapWidth: -1,
+		BytesPerLine:    -1,
+	})
+	check.Eq(t, err, nil)
+	check.Eq(t, strings.Contains(string(have), "  S = 'abcdefgh'\r\n"), true)
+	check.Eq(t, strings.Contains(string(have), "  B = {\r\n    0102030405}\r\n"), true)
+}
+
+func TestPrintShortestFloats(t *testing.T) {
+	third, _ := dfm.ParseExtended("0.33333333333333333334")
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{
+		{Name: "A", Value: dfm.Float(0.1)},
+		{Name: "B", Value: dfm.Float(2)},
+		{Name: "C", Value: dfm.Float(1e20)},
+		{Name: "D", Value: dfm.Float(-1.5e-7)},
+		{Name: "E", Value: dfm.Single(0.1)},
+		{Name: "F", Value: dfm.Date(45000.5)},
+		{Name: "G", Value: third},
+		{Name: "H", Value: dfm.Float(math.NaN())},
+	}}
+	have, err := obj.PrintWithOptions(dfm.PrintOptions{FloatStyle: dfm.ShortestFloats})
+	check.Eq(t, err, nil)
+	code := "object TA\r\n" +
+		"  A = 0.1\r\n" +
+		"  B = 2.0\r\n" +
+		"  C = 1E20\r\n" +
+		"  D = -1.5E-7\r\n" +
+		"  E = 0.1s\r\n" +
+		"  F = 45000.5d\r\n" +
+		"  G = 0.33333333333333333334\r\n" +
+		"  H = 0.0\r\n" +
+		"end\r\n"
+	check.Eq(t, string(have), code)
+
+	parsed, err := dfm.ParseStringWithOptions(code, dfm.ParseOptions{ExactFloats: true})
+	check.Eq(t, err, nil)
+	check.Eq(t, parsed.Properties[6].Value, third)
+	for i, typ := range []string{"dfm.Extended", "dfm.Extended", "dfm.Extended", "dfm.Extended", "dfm.Single", "dfm.Date"} {
+		check.Eq(t, fmt.Sprintf("%T", parsed.Properties[i].Value), typ)
+	}
+}
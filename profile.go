@@ -0,0 +1,68 @@
+package dfm
+
+import "fmt"
+
+// Profile is a version of the Delphi IDE. Different versions write DFMs
+// differently. Use a Profile's PrintOptions to print DFMs the way that version
+// does, so teams working with different versions get the same output.
+//
+// The profiles differ in encoding, string escaping, byte order marks and
+// Explicit* properties. Floating point numbers are written the same way by all
+// of them. Every version since Delphi 7 converts Extended values with
+// FloatToStrF(Value, ffFixed, 16, 18) and Single, Currency and Date values with
+// FloatToStr, always with a dot as decimal separator, so all profiles use
+// DelphiFloats.
+type Profile int
+
+const (
+	// DelphiXE4 is RAD Studio XE4, which is what Print and WriteTo emulate.
+	// It is like every unicode version of Delphi from 2009 to XE8. Non-ASCII
+	// string characters are written as #nnn escapes, the code is ASCII or,
+	// for unicode identifiers, UTF-8 with a byte order mark.
+	DelphiXE4 Profile = iota
+	// Delphi7 writes ANSI code in the system code page, without byte order
+	// mark. Non-ASCII string characters are written as they are if they exist
+	// in the code page. There are no Explicit* properties.
+	Delphi7
+	// Delphi2007 is like Delphi7 but knows the Explicit* properties, which
+	// were introduced in Delphi 2006.
+	Delphi2007
+	// Delphi10 is for Delphi 10.x, 11 and 12. They are like DelphiXE4 but write
+	// non-ASCII string characters as they are, in UTF-8 with a byte order
+	// mark.
+	Delphi10
+)
+
+func (p Profile) String() string {
+	switch p {
+	case DelphiXE4:
+		return "DelphiXE4"
+	case Delphi7:
+		return "Delphi7"
+	case Delphi2007:
+		return "Delphi2007"
+	case Delphi10:
+		return "Delphi10"
+	}
+	return fmt.Sprintf("Profile(%d)", int(p))
+}
+
+// PrintOptions returns the options that print DFMs like this version of
+// Delphi. For the ANSI versions, set PrintOptions.CodePage to the system code
+// page if it is not Windows-1252.
+func (p Profile) PrintOptions() PrintOptions {
+	opts := DefaultPrintOptions()
+	opts.FloatStyle = DelphiFloats
+	switch p {
+	case Delphi7:
+		opts.Encoding = ANSI
+		opts.RawStrings = true
+		opts.SkipExplicitProperties = true
+	case Delphi2007:
+		opts.Encoding = ANSI
+		opts.RawStrings = true
+	case Delphi10:
+		opts.RawStrings = true
+	}
+	return opts
+}
@@ -0,0 +1,84 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+func TestPrintProfiles(t *testing.T) {
+	obj := dfm.Object{Name: "Form1", Type: "TForm1", Properties: []dfm.Property{
+		{Name: "Caption", Value: dfm.String("Größe 日")},
+		{Name: "Ratio", Value: dfm.Float(0.5)},
+		{Name: "Scale", Value: dfm.Single(1.1)},
+		{Value: &dfm.Object{Name: "Panel1", Type: "TPanel", Properties: []dfm.Property{
+			{Name: "Align", Value: dfm.Identifier("alTop")},
+			{Name: "ExplicitLeft", Value: dfm.Int(8)},
+			{Name: "ExplicitWidth", Value: dfm.Int(100)},
+		}}},
+	}}
+	tests := []struct {
+		profile dfm.Profile
+		want    string
+	}{
+		{dfm.DelphiXE4, "object Form1: TForm1\r\n" +
+			"  Caption = 'Gr'#246#223'e '#26085\r\n" +
+			"  Ratio = 0.500000000000000000\r\n" +
+			"  Scale = 1.10000002384186s\r\n" +
+			"  object Panel1: TPanel\r\n" +
+			"    Align = alTop\r\n" +
+			"    ExplicitLeft = 8\r\n" +
+			"    ExplicitWidth = 100\r\n" +
+			"  end\r\n" +
+			"end\r\n"},
+		{dfm.Delphi7, "object Form1: TForm1\r\n" +
+			"  Caption = 'Gr\xF6\xDFe '#26085\r\n" +
+			"  Ratio = 0.500000000000000000\r\n" +
+			"  Scale = 1.10000002384186s\r\n" +
+			"  object Panel1: TPanel\r\n" +
+			"    Align = alTop\r\n" +
+			"  end\r\n" +
+			"end\r\n"},
+		{dfm.Delphi2007, "object Form1: TForm1\r\n" +
+			"  Caption = 'Gr\xF6\xDFe '#26085\r\n" +
+			"  Ratio = 0.500000000000000000\r\n" +
+			"  Scale = 1.10000002384186s\r\n" +
+			"  object Panel1: TPanel\r\n" +
+			"    Align = alTop\r\n" +
+			"    ExplicitLeft = 8\r\n" +
+			"    ExplicitWidth = 100\r\n" +
+			"  end\r\n" +
+			"end\r\n"},
+		{dfm.Delphi10, "\xEF\xBB\xBFobject Form1: TForm1\r\n" +
+			"  Caption = 'Größe 日'\r\n" +
+			"  Ratio = 0.500000000000000000\r\n" +
+			"  Scale = 1.10000002384186s\r\n" +
+			"  object Panel1: TPanel\r\n" +
+			"    Align = alTop\r\n" +
+			"    ExplicitLeft = 8\r\n" +
+			"    ExplicitWidth = 100\r\n" +
+			"  end\r\n" +
+			"end\r\n"},
+	}
+	for _, test := range tests {
+		have, err := obj.PrintWithOptions(test.profile.PrintOptions())
+		check.Eq(t, err, nil, test.profile)
+		check.Eq(t, string(have), test.want, test.profile)
+	}
+}
+
+func TestDelphiXE4ProfileIsTheDefault(t *testing.T) {
+	check.Eq(t, dfm.DelphiXE4.PrintOptions(), dfm.DefaultPrintOptions())
+}
+
+func TestDelphi7ProfileUsesCodePage(t *testing.T) {
+	obj := dfm.Object{Type: "TA", Properties: []dfm.Property{
+		{Name: "S", Value: dfm.String("Привет")},
+	}}
+	opts := dfm.Delphi7.PrintOptions()
+	opts.CodePage = 1251
+	have, err := obj.PrintWithOptions(opts)
+	check.Eq(t, err, nil)
+	check.Eq(t, string(have), "object TA\r\n  S = '\xCF\xF0\xE8\xE2\xE5\xF2'\r\nend\r\n")
+}
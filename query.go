@@ -0,0 +1,640 @@
+package dfm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Selector is a compiled query for objects and properties, see ParseSelector
+// for the syntax.
+type Selector struct {
+	text         string
+	alternatives []complexSelector
+}
+
+// Match is a result of Object.Query.
+type Match struct {
+	// Path is the location of the match relative to the queried object, like
+	// Cursor.Path, e.g. "Panel1.Grid" or "Panel1.Grid.Columns[0].FieldName".
+	Path string
+	// Object is the matched object or, for a property, the object that has the
+	// property.
+	Object *Object
+	// Value is the matched *Object or the value of the matched property.
+	Value PropertyValue
+}
+
+// String formats the match as the path followed by the object type or the
+// property value, e.g.
+//
+//     Panel1.Edit1: TDBEdit
+//     Panel1.Edit1.DataField = 'ID'
+func (m Match) String() string {
+	if obj, ok := m.Value.(*Object); ok {
+		return m.Path + ": " + obj.Type
+	}
+	return m.Path + " = " + formatValue(m.Value)
+}
+
+// Query returns all objects and properties in the tree below o, including o
+// itself, that match the selector, see ParseSelector for the syntax. Matches
+// are returned in the order in which they appear in the code.
+//
+// For example, this finds all TDBEdits inside TTabSheets without a DataField:
+//
+//     edits, err := form.Query("TTabSheet TDBEdit[DataField='']")
+func (o *Object) Query(selector string) ([]Match, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.Query(o), nil
+}
+
+// ParseSelector compiles a selector. Selectors are similar to CSS selectors. A
+// selector is a list of object patterns separated by combinators:
+//
+//     TButton                an object of type TButton
+//     #Button1               the object named Button1
+//     TButton#OK             both
+//     *                      any object
+//     TPanel TButton         a TButton anywhere below a TPanel
+//     TPanel > TButton       a TButton that is a direct child of a TPanel
+//     TButton, TEdit         a TButton or a TEdit
+//
+// Types and names are compared case-insensitively, like Delphi does. Object
+// patterns can have predicates in brackets about their properties:
+//
+//     [Caption]              the property exists
+//     [!Caption]             the property does not exist
+//     [Caption='OK']         the property has this value
+//     [Caption!='OK']        the property has a different value
+//     [Caption^='O']         the value starts with this text
+//     [Caption$='K']         the value ends with this text
+//     [Caption*='K']         the value contains this text
+//     [Width>100]            numeric comparisons with <, <=, > and >=
+//
+// Property names can contain dots like Font.Height and indexes into Items,
+// Sets and Tuples, e.g. [Columns[0].FieldName='ID']. Values are strings in
+// single or double quotes, numbers or identifiers like alClient or True.
+// Strings are compared case-sensitively, identifiers case-insensitively and
+// numbers by value. Other values are compared in their DFM notation, e.g.
+// [Anchors*=akTop], a BadValue in its original code. Delphi does not store properties that have their default
+// value, missing properties are therefore treated like the empty text '', so
+// [DataField=''] matches objects without a DataField.
+//
+// A property after the last object pattern selects that property instead of
+// the object:
+//
+//     TDBGrid.Columns[0].FieldName
+//     #Form1 > *.Caption
+func ParseSelector(selector string) (*Selector, error) {
+	p := selectorParser{text: selector}
+	s := &Selector{text: selector}
+	for {
+		alt, err := p.complexSelector()
+		if err != nil {
+			return nil, err
+		}
+		s.alternatives = append(s.alternatives, alt)
+		if p.atEnd() {
+			return s, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// String returns the selector's text as passed to ParseSelector.
+func (s *Selector) String() string {
+	return s.text
+}
+
+// Query returns all matches in the tree below root, including root, see
+// Object.Query.
+func (s *Selector) Query(root *Object) []Match {
+	var matches []Match
+	var visit func(chain []*Object, path string)
+	visit = func(chain []*Object, path string) {
+		obj := chain[len(chain)-1]
+		seen := make(map[string]bool)
+		add := func(m Match) {
+			if !seen[m.Path] {
+				seen[m.Path] = true
+				matches = append(matches, m)
+			}
+		}
+		for _, alt := range s.alternatives {
+			if !alt.matches(chain, len(alt.compounds)-1, len(chain)-1) {
+				continue
+			}
+			if alt.property == nil {
+				add(Match{Path: path, Object: obj, Value: obj})
+			} else if v, sub, ok := resolveProperty(obj, alt.property); ok {
+				add(Match{Path: joinPath(path, sub), Object: obj, Value: v})
+			}
+		}
+		for _, child := range obj.Children() {
+			visit(append(chain[:len(chain):len(chain)], child),
+				joinPath(path, objectPathName(child)))
+		}
+	}
+	visit([]*Object{root}, "")
+	return matches
+}
+
+// complexSelector is a chain of compound selectors like "TPanel > TButton".
+type complexSelector struct {
+	compounds []compoundSelector
+	// property is set if the selector ends in a property.
+	property []pathPart
+}
+
+// matches reports whether the compounds up to and including index i match the
+// objects in chain, where chain[j] must match compounds[i]. chain holds the
+// objects from the queried root down to the object in question.
+func (s complexSelector) matches(chain []*Object, i, j int) bool {
+	c := s.compounds[i]
+	if !c.matches(chain[j]) {
+		return false
+	}
+	if i == 0 {
+		return true
+	}
+	if c.child {
+		return j > 0 && s.matches(chain, i-1, j-1)
+	}
+	for k := j - 1; k >= 0; k-- {
+		if s.matches(chain, i-1, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// compoundSelector matches a single object, like "TButton#OK[Default=True]".
+type compoundSelector struct {
+	// typ and name are empty if they match anything.
+	typ        string
+	name       string
+	predicates []predicate
+	// child is true if the object must be a direct child of the object matched
+	// by the previous compound, i.e. they are separated by '>'.
+	child bool
+}
+
+func (c compoundSelector) matches(obj *Object) bool {
+	if c.typ != "" && !strings.EqualFold(c.typ, obj.Type) {
+		return false
+	}
+	if c.name != "" && !strings.EqualFold(c.name, obj.Name) {
+		return false
+	}
+	for _, p := range c.predicates {
+		if !p.matches(obj) {
+			return false
+		}
+	}
+	return true
+}
+
+// predicate is a condition on a property, like [Width>100].
+type predicate struct {
+	property []pathPart
+	// op is "" if the property must exist and "!" if it must not exist.
+	op       string
+	value    string
+	number   float64
+	isNumber bool
+}
+
+func (p predicate) matches(obj *Object) bool {
+	v, _, ok := resolveProperty(obj, p.property)
+	switch p.op {
+	case "":
+		return ok
+	case "!":
+		return !ok
+	case "<", "<=", ">", ">=":
+		f, isNumber := numberValue(v)
+		if !ok || !isNumber || !p.isNumber {
+			return false
+		}
+		switch p.op {
+		case "<":
+			return f < p.number
+		case "<=":
+			return f <= p.number
+		case ">":
+			return f > p.number
+		default:
+			return f >= p.number
+		}
+	case "=":
+		return p.equals(v)
+	case "!=":
+		return !p.equals(v)
+	}
+
+	text, want := "", p.value
+	if ok {
+		text = valueText(v)
+	}
+	if ignoresCase(v) {
+		text, want = strings.ToLower(text), strings.ToLower(want)
+	}
+	switch p.op {
+	case "^=":
+		return strings.HasPrefix(text, want)
+	case "$=":
+		return strings.HasSuffix(text, want)
+	default:
+		return strings.Contains(text, want)
+	}
+}
+
+// equals compares the predicate's value to v, which is nil if the property is
+// missing.
+func (p predicate) equals(v PropertyValue) bool {
+	if v == nil {
+		return p.value == ""
+	}
+	if f, ok := numberValue(v); ok && p.isNumber {
+		return f == p.number
+	}
+	if ignoresCase(v) {
+		return strings.EqualFold(valueText(v), p.value)
+	}
+	return valueText(v) == p.value
+}
+
+// pathPart is a property name or an index into Items, a Set or a Tuple.
+type pathPart struct {
+	name  string
+	index int
+}
+
+func (p pathPart) isIndex() bool {
+	return p.name == ""
+}
+
+// resolveProperty finds the non-object property of obj at the given path. It
+// returns the value and the path with the actual property names.
+func resolveProperty(obj *Object, path []pathPart) (PropertyValue, string, bool) {
+	props := obj.Properties
+	var value PropertyValue
+	var text string
+	for _, part := range path {
+		if !part.isIndex() {
+			if props == nil {
+				return nil, "", false
+			}
+			prop := findPropertyIn(props, part.name)
+			if prop == nil {
+				return nil, "", false
+			}
+			value, props = prop.Value, nil
+			text = joinPath(text, prop.Name)
+			continue
+		}
+
+		switch v := value.(type) {
+		case Items:
+			if part.index >= len(v) {
+				return nil, "", false
+			}
+			value, props = nil, v[part.index]
+			if props == nil {
+				props = []Property{}
+			}
+		case Set:
+			if part.index >= len(v) {
+				return nil, "", false
+			}
+			value = v[part.index]
+		case Tuple:
+			if part.index >= len(v) {
+				return nil, "", false
+			}
+			value = v[part.index]
+		default:
+			return nil, "", false
+		}
+		text = indexPath(text, part.index)
+	}
+	// A path that ends in a collection item has no value.
+	return value, text, value != nil
+}
+
+func joinPath(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "." + b
+}
+
+// numberValue converts all integer and floating point values to float64.
+func numberValue(v PropertyValue) (float64, bool) {
+	switch v := v.(type) {
+	case Int:
+		return float64(v), true
+	case Int64:
+		return float64(v), true
+	case UInt64:
+		return float64(v), true
+	case Float:
+		return float64(v), true
+	case Extended:
+		return v.Float64(), true
+	case Single:
+		return float64(v), true
+	case Currency:
+		return v.Float64(), true
+	case Date:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// ignoresCase reports whether v is compared case-insensitively, which is the
+// case for identifiers and everything that contains them.
+func ignoresCase(v PropertyValue) bool {
+	switch v.(type) {
+	case Identifier, Bool, Set:
+		return true
+	}
+	return false
+}
+
+// valueText is the text that predicates compare with, which is the plain text
+// for strings and identifiers and the DFM notation otherwise.
+func valueText(v PropertyValue) string {
+	switch v := v.(type) {
+	case String:
+		return string(v)
+	case Identifier:
+		return string(v)
+	}
+	return formatValue(v)
+}
+
+// formatValue returns the DFM notation of v without line breaks in strings.
+func formatValue(v PropertyValue) string {
+	p := newPrinter(PrintOptions{StringWrapWidth: -1, BytesPerLine: -1})
+	p.propertyValue(v)
+	return p.String()
+}
+
+type selectorParser struct {
+	text string
+	pos  int
+}
+
+func (p *selectorParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("dfm.ParseSelector: %s at offset %d in %q",
+		fmt.Sprintf(format, args...), p.pos, p.text)
+}
+
+func (p *selectorParser) atEnd() bool {
+	return p.pos >= len(p.text)
+}
+
+func (p *selectorParser) peek() byte {
+	if p.atEnd() {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+func (p *selectorParser) skipSpace() bool {
+	start := p.pos
+	for !p.atEnd() && strings.IndexByte(" \t\r\n", p.peek()) != -1 {
+		p.pos++
+	}
+	return p.pos > start
+}
+
+// accept skips s and returns true if the text continues with s.
+func (p *selectorParser) accept(s string) bool {
+	if strings.HasPrefix(p.text[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *selectorParser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("%q expected", s)
+	}
+	return nil
+}
+
+func (p *selectorParser) complexSelector() (complexSelector, error) {
+	var s complexSelector
+	p.skipSpace()
+	child := false
+	for {
+		c, err := p.compoundSelector()
+		if err != nil {
+			return s, err
+		}
+		c.child = child
+		s.compounds = append(s.compounds, c)
+
+		if p.accept(".") {
+			s.property, err = p.propertyPath()
+			if err != nil {
+				return s, err
+			}
+			p.skipSpace()
+			if !p.atEnd() && p.peek() != ',' {
+				return s, p.errorf("a property must come last")
+			}
+			return s, nil
+		}
+
+		space := p.skipSpace()
+		if p.atEnd() || p.peek() == ',' {
+			return s, nil
+		}
+		child = p.accept(">")
+		if child {
+			p.skipSpace()
+		} else if !space {
+			return s, p.errorf("unexpected %q", p.peek())
+		}
+	}
+}
+
+func (p *selectorParser) compoundSelector() (compoundSelector, error) {
+	var c compoundSelector
+	start := p.pos
+	if !p.accept("*") {
+		c.typ = p.identifier()
+	}
+	if p.accept("#") {
+		c.name = p.identifier()
+		if c.name == "" {
+			return c, p.errorf("name expected")
+		}
+	}
+	for p.accept("[") {
+		pred, err := p.predicate()
+		if err != nil {
+			return c, err
+		}
+		c.predicates = append(c.predicates, pred)
+	}
+	// An empty object pattern is allowed before a property, e.g. ".Caption".
+	if p.pos == start && p.peek() != '.' {
+		return c, p.errorf("type, name or '*' expected")
+	}
+	return c, nil
+}
+
+func (p *selectorParser) predicate() (predicate, error) {
+	var pred predicate
+	p.skipSpace()
+	missing := p.accept("!")
+	var err error
+	pred.property, err = p.propertyPath()
+	if err != nil {
+		return pred, err
+	}
+	p.skipSpace()
+	if p.accept("]") {
+		if missing {
+			pred.op = "!"
+		}
+		return pred, nil
+	}
+	if missing {
+		return pred, p.errorf("']' expected")
+	}
+
+	for _, op := range []string{"!=", "^=", "$=", "*=", "<=", ">=", "=", "<", ">"} {
+		if p.accept(op) {
+			pred.op = op
+			break
+		}
+	}
+	if pred.op == "" {
+		return pred, p.errorf("operator or ']' expected")
+	}
+	p.skipSpace()
+	if err := p.value(&pred); err != nil {
+		return pred, err
+	}
+	p.skipSpace()
+	return pred, p.expect("]")
+}
+
+// value parses a quoted string, a number or an identifier.
+func (p *selectorParser) value(pred *predicate) error {
+	if quote := p.peek(); quote == '\'' || quote == '"' {
+		p.pos++
+		var s strings.Builder
+		for {
+			if p.atEnd() {
+				return p.errorf("unterminated string")
+			}
+			c := p.text[p.pos]
+			p.pos++
+			if c == quote {
+				if p.peek() != quote {
+					break
+				}
+				p.pos++
+			}
+			s.WriteByte(c)
+		}
+		pred.value = s.String()
+		return nil
+	}
+
+	start := p.pos
+	if c := p.peek(); c == '-' || c == '+' || c == '.' || '0' <= c && c <= '9' {
+		p.pos++
+	}
+	for p.pos > start && !p.atEnd() && strings.IndexByte("0123456789.eE", p.peek()) != -1 {
+		p.pos++
+		if (p.text[p.pos-1] == 'e' || p.text[p.pos-1] == 'E') &&
+			(p.peek() == '-' || p.peek() == '+') {
+			p.pos++
+		}
+	}
+	if p.pos > start {
+		f, err := strconv.ParseFloat(p.text[start:p.pos], 64)
+		if err != nil {
+			p.pos = start
+			return p.errorf("invalid number")
+		}
+		pred.value, pred.number, pred.isNumber = p.text[start:p.pos], f, true
+		return nil
+	}
+
+	pred.value = p.identifier()
+	if pred.value == "" {
+		return p.errorf("value expected")
+	}
+	return nil
+}
+
+// propertyPath parses a property name, which might contain dots, followed by
+// indexes in brackets and more property names, e.g. "Columns[0].Font.Name".
+func (p *selectorParser) propertyPath() ([]pathPart, error) {
+	var path []pathPart
+	for {
+		name := p.identifier()
+		if name == "" {
+			return nil, p.errorf("property name expected")
+		}
+		for p.peek() == '.' && p.pos+1 < len(p.text) && isIdentStart(p.text[p.pos+1:]) {
+			p.pos++
+			name += "." + p.identifier()
+		}
+		path = append(path, pathPart{name: name})
+
+		for p.accept("[") {
+			start := p.pos
+			for !p.atEnd() && '0' <= p.peek() && p.peek() <= '9' {
+				p.pos++
+			}
+			index, err := strconv.Atoi(p.text[start:p.pos])
+			if err != nil {
+				p.pos = start
+				return nil, p.errorf("index expected")
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			path = append(path, pathPart{index: index})
+		}
+		if !(path[len(path)-1].isIndex() && p.peek() == '.') {
+			return path, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) identifier() string {
+	start := p.pos
+	for !p.atEnd() {
+		r, size := utf8.DecodeRuneInString(p.text[p.pos:])
+		if !(r == '_' || unicode.IsLetter(r) || p.pos > start && unicode.IsDigit(r)) {
+			break
+		}
+		p.pos += size
+	}
+	return p.text[start:p.pos]
+}
+
+func isIdentStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r)
+}
@@ -0,0 +1,207 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+const queryDFM = `object Form1: TForm1
+  Caption = 'Main'
+  object Pages: TPageControl
+    object Tab1: TTabSheet
+      Caption = 'First'
+      object Edit1: TDBEdit
+        DataField = 'ID'
+        Width = 121
+      end
+      object Edit2: TDBEdit
+        Width = 80
+      end
+      object Panel1: TPanel
+        object Edit3: TDBEdit
+          DataField = ''
+          Anchors = [akLeft, akTop]
+        end
+      end
+    end
+  end
+  object Edit4: TDBEdit
+    Enabled = False
+  end
+  object Grid: TDBGrid
+    Columns = <
+      item
+        FieldName = 'ID'
+        Width = 5
+      end
+      item
+        FieldName = 'Name'
+        Font.Height = -11
+      end>
+  end
+end`
+
+func query(t *testing.T, selector string) []string {
+	t.Helper()
+	form, err := dfm.ParseString(queryDFM)
+	check.Eq(t, err, nil)
+	matches, err := form.Query(selector)
+	check.Eq(t, err, nil, selector)
+	paths := []string{}
+	for _, m := range matches {
+		paths = append(paths, m.Path)
+	}
+	return paths
+}
+
+func TestQueryObjects(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"TDBEdit", []string{
+			"Pages.Tab1.Edit1",
+			"Pages.Tab1.Edit2",
+			"Pages.Tab1.Panel1.Edit3",
+			"Edit4",
+		}},
+		{"tdbedit#EDIT2", []string{"Pages.Tab1.Edit2"}},
+		{"#Form1", []string{""}},
+		{"TTabSheet TDBEdit", []string{
+			"Pages.Tab1.Edit1",
+			"Pages.Tab1.Edit2",
+			"Pages.Tab1.Panel1.Edit3",
+		}},
+		{"TTabSheet > TDBEdit", []string{
+			"Pages.Tab1.Edit1",
+			"Pages.Tab1.Edit2",
+		}},
+		{"TForm1 > *", []string{"Pages", "Edit4", "Grid"}},
+		{"TForm1 > * > * > * TDBEdit", []string{"Pages.Tab1.Panel1.Edit3"}},
+		{"TTabSheet TDBEdit[DataField='']", []string{
+			"Pages.Tab1.Edit2",
+			"Pages.Tab1.Panel1.Edit3",
+		}},
+		{"TDBEdit[DataField]", []string{
+			"Pages.Tab1.Edit1",
+			"Pages.Tab1.Panel1.Edit3",
+		}},
+		{"TDBEdit[!DataField]", []string{"Pages.Tab1.Edit2", "Edit4"}},
+		{"TDBEdit[DataField!='ID']", []string{
+			"Pages.Tab1.Edit2",
+			"Pages.Tab1.Panel1.Edit3",
+			"Edit4",
+		}},
+		{"*[Width>=80][Width<121]", []string{"Pages.Tab1.Edit2"}},
+		{"*[Width>100]", []string{"Pages.Tab1.Edit1"}},
+		{"*[Width=121.0]", []string{"Pages.Tab1.Edit1"}},
+		{"*[Enabled=false]", []string{"Edit4"}},
+		{"*[Caption^='Fi']", []string{"Pages.Tab1"}},
+		{"*[Caption$=\"ain\"]", []string{""}},
+		{"*[Caption*='i']", []string{"", "Pages.Tab1"}},
+		{"*[Caption*='I']", []string{}},
+		{"*[Anchors*=AKTOP]", []string{"Pages.Tab1.Panel1.Edit3"}},
+		{"*[Columns[1].FieldName='Name']", []string{"Grid"}},
+		{"*[Columns[1].Font.Height=-11]", []string{"Grid"}},
+		{"*[Columns[2].FieldName]", []string{}},
+		{"TPanel, TDBGrid, #Panel1", []string{"Pages.Tab1.Panel1", "Grid"}},
+	}
+	for _, test := range tests {
+		check.Eq(t, query(t, test.selector), test.want, test.selector)
+	}
+}
+
+func TestQueryProperties(t *testing.T) {
+	tests := []struct {
+		selector string
+		want     []string
+	}{
+		{"TDBEdit.Width", []string{
+			"Pages.Tab1.Edit1.Width",
+			"Pages.Tab1.Edit2.Width",
+		}},
+		{".caption", []string{"Caption", "Pages.Tab1.Caption"}},
+		{"#Grid.Columns[1].Font.Height", []string{"Grid.Columns[1].Font.Height"}},
+		{"#Grid.Columns[0]", []string{}},
+		{"#Edit3.Anchors[1]", []string{"Pages.Tab1.Panel1.Edit3.Anchors[1]"}},
+	}
+	for _, test := range tests {
+		check.Eq(t, query(t, test.selector), test.want, test.selector)
+	}
+}
+
+func TestQueryMatches(t *testing.T) {
+	form, err := dfm.ParseString(queryDFM)
+	check.Eq(t, err, nil)
+
+	matches, err := form.Query("TPanel, TPanel .DataField")
+	check.Eq(t, err, nil)
+	check.Eq(t, len(matches), 2)
+	panel := form.FindObject("Panel1")
+	check.Eq(t, matches[0], dfm.Match{
+		Path:   "Pages.Tab1.Panel1",
+		Object: panel,
+		Value:  panel,
+	})
+	check.Eq(t, matches[1], dfm.Match{
+		Path:   "Pages.Tab1.Panel1.Edit3.DataField",
+		Object: form.FindObject("Edit3"),
+		Value:  dfm.String(""),
+	})
+	check.Eq(t, matches[0].String(), "Pages.Tab1.Panel1: TPanel")
+	check.Eq(t, matches[1].String(), "Pages.Tab1.Panel1.Edit3.DataField = ''")
+}
+
+func TestQueryTreesWithBadValues(t *testing.T) {
+	form, err := dfm.ParseStringWithOptions(`object F: TF
+  C = )
+  D = 'a'
+end`, dfm.ParseOptions{Recover: true})
+	check.Eq(t, err != nil, true)
+
+	matches, err := form.Query("TF[C*=')']")
+	check.Eq(t, err, nil)
+	check.Eq(t, len(matches), 1)
+
+	matches, err = form.Query(".C")
+	check.Eq(t, err, nil)
+	check.Eq(t, len(matches), 1)
+	check.Eq(t, matches[0].Value, dfm.BadValue("C = )"))
+	check.Eq(t, matches[0].String(), "C = C = )")
+}
+
+func TestSelectorCanBeReused(t *testing.T) {
+	s, err := dfm.ParseSelector("TDBGrid.Columns[0].Width")
+	check.Eq(t, err, nil)
+	check.Eq(t, s.String(), "TDBGrid.Columns[0].Width")
+	form, err := dfm.ParseString(queryDFM)
+	check.Eq(t, err, nil)
+	matches := s.Query(form)
+	check.Eq(t, len(matches), 1)
+	check.Eq(t, matches[0].Value, dfm.Int(5))
+}
+
+func TestInvalidSelectors(t *testing.T) {
+	for _, selector := range []string{
+		"",
+		"TButton,",
+		"#",
+		"TButton>",
+		"TButton[",
+		"TButton[Caption",
+		"TButton[Caption=]",
+		"TButton[Caption=='x']",
+		"TButton[Caption='x]",
+		"TButton[!Caption='x']",
+		"TButton[Width=1.2.3]",
+		"TButton[Items[x]]",
+		"TButton.Caption TEdit",
+		"TButton.",
+		"TButton%",
+	} {
+		_, err := dfm.ParseSelector(selector)
+		check.Neq(t, err, nil, selector)
+	}
+}
@@ -5,8 +5,13 @@ import "fmt"
 type token struct {
 	tokenType tokenType
 	text      string
+	// index is the index of the token's first byte in the tokenizer's code.
+	index int
 	// line and col both start at 1.
 	line, col int
+	// offset is the byte offset of the token in the code, endOffset is the
+	// byte offset right after the token.
+	offset, endOffset int
 }
 
 // tokenType is a rune because single characters are used directly as their
@@ -24,6 +29,12 @@ const (
 	tokenFloat      tokenType = 261
 )
 
+// endIndex is the index right after the token's last byte in the tokenizer's
+// code.
+func (t token) endIndex() int {
+	return t.index + t.endOffset - t.offset
+}
+
 func (t token) String() string {
 	return fmt.Sprintf("%v: %q at %d:%d", t.tokenType, t.text, t.line, t.col)
 }
@@ -1,8 +1,13 @@
 package dfm
 
-import "unicode"
+import (
+	"bytes"
+	"io"
+	"unicode"
+	"unicode/utf8"
+)
 
-func newTokenizer(code []rune) tokenizer {
+func newTokenizer(code []byte) tokenizer {
 	return tokenizer{
 		code: code,
 		line: 1,
@@ -10,14 +15,76 @@ func newTokenizer(code []rune) tokenizer {
 	}
 }
 
+// tokenizer splits UTF-8 or ANSI encoded code into tokens. It works on
+// the bytes directly and decodes runes on the fly.
 type tokenizer struct {
-	code []rune
+	code []byte
 	cur  int
 	line int
 	col  int
+	// base is the offset of code[0] in the original input. It is non-zero if
+	// the input starts with a byte order mark that is not part of code or if
+	// a streaming tokenizer has discarded code already.
+	base int
+	// cp is the code page of ANSI encoded code, it is nil for UTF-8.
+	cp *codePage
+	// src is only set when streaming, see Decoder. In that case code holds only
+	// a window of the input which is refilled from src when needed. srcErr is
+	// the first read error, io.EOF at the end of the input.
+	src    io.Reader
+	srcErr error
+}
+
+// streamChunkSize is the number of bytes a streaming tokenizer reads at once.
+const streamChunkSize = 4096
+
+// more reads the next chunk of code from src and appends it to code. It returns
+// false if there is no more code.
+func (t *tokenizer) more() bool {
+	if t.src == nil || t.srcErr != nil {
+		return false
+	}
+	n := len(t.code)
+	if cap(t.code)-n < streamChunkSize {
+		grown := make([]byte, n, 2*n+streamChunkSize)
+		copy(grown, t.code)
+		t.code = grown
+	}
+	for len(t.code) == n && t.srcErr == nil {
+		read, err := t.src.Read(t.code[n : n+streamChunkSize])
+		t.code = t.code[:n+read]
+		t.srcErr = err
+	}
+	return len(t.code) > n
+}
+
+// discardRead drops the code that was already tokenized from a streaming
+// tokenizer. The remaining code is copied to a new slice, so slices returned
+// from findClosingBrace stay valid.
+func (t *tokenizer) discardRead() {
+	if t.src != nil && t.cur >= streamChunkSize {
+		rest := make([]byte, len(t.code)-t.cur, len(t.code)-t.cur+streamChunkSize)
+		copy(rest, t.code[t.cur:])
+		t.code = rest
+		t.base += t.cur
+		t.cur = 0
+	}
+}
+
+// decode converts the given part of the code to a UTF-8 string.
+func (t *tokenizer) decode(code []byte) string {
+	if t.cp != nil && !allASCII(code) {
+		return string(t.cp.decode(code))
+	}
+	if t.cp == nil && !utf8.Valid(code) {
+		// Invalid bytes become utf8.RuneError.
+		return string(bytes.Runes(code))
+	}
+	return string(code)
 }
 
 func (t *tokenizer) next() token {
+	t.discardRead()
 	haveType := tokenIllegal
 	start := t.cur
 	line, col := t.line, t.col
@@ -31,8 +98,11 @@ func (t *tokenizer) next() token {
 	case 0:
 		return token{
 			tokenType: tokenEOF,
+			index:     start,
 			line:      line,
 			col:       col,
+			offset:    t.base + start,
+			endOffset: t.base + start,
 		}
 	case '+', '-', '[', ']', '(', ')', '{', '}', '<', '>', '=', ':', '.', ',':
 		t.nextRune()
@@ -85,6 +155,12 @@ func (t *tokenizer) next() token {
 				for digit(t.nextRune()) {
 				}
 			}
+			// Delphi marks Single, Currency and Date values with a suffix.
+			switch t.currentRune() {
+			case 's', 'S', 'c', 'C', 'd', 'D':
+				t.nextRune()
+				haveType = tokenFloat
+			}
 		} else {
 			// For an illegal token we only consume one rune. The next call to
 			// this function then tries to continue after the illegal rune.
@@ -94,9 +170,12 @@ func (t *tokenizer) next() token {
 
 	return token{
 		tokenType: haveType,
-		text:      string(t.code[start:t.cur]),
+		text:      t.decode(t.code[start:t.cur]),
+		index:     start,
 		line:      line,
 		col:       col,
+		offset:    t.base + start,
+		endOffset: t.base + t.cur,
 	}
 }
 
@@ -111,45 +190,77 @@ func (t *tokenizer) next() token {
 // which would be tokenized to integers and words. Since binary data is the
 // largest part of a typical DFM, this function allows the parser to process
 // this much quicker than re-combining integers and words.
-func (t *tokenizer) findClosingBrace() []rune {
-	oldLine, oldCol := t.line, t.col
-
-	for i := t.cur; i < len(t.code); i++ {
-		if t.code[i] == '\n' {
-			t.line++
-			t.col = 1
-		} else {
-			t.col++
-		}
-
-		if t.code[i] == '}' {
-			part := t.code[t.cur:i]
-			t.cur = i
+func (t *tokenizer) findClosingBrace() []byte {
+	searched := t.cur
+	for {
+		if i := bytes.IndexByte(t.code[searched:], '}'); i != -1 {
+			part := t.code[t.cur : searched+i]
+			t.cur = searched + i
+			t.advancePosition(part)
 			return part
 		}
+		searched = len(t.code)
+		if !t.more() {
+			// No closing brace was found.
+			return nil
+		}
 	}
+}
 
-	// No closing brace was found.
-	t.line, t.col = oldLine, oldCol
-	return nil
+// advancePosition moves line and col behind the given code.
+func (t *tokenizer) advancePosition(code []byte) {
+	if n := bytes.Count(code, []byte{'\n'}); n > 0 {
+		t.line += n
+		t.col = 1
+		code = code[bytes.LastIndexByte(code, '\n')+1:]
+	}
+	if t.cp != nil {
+		t.col += t.cp.runeCount(code)
+	} else {
+		t.col += utf8.RuneCount(code)
+	}
 }
 
 func (t *tokenizer) currentRune() rune {
-	if t.cur < len(t.code) {
-		return t.code[t.cur]
+	if t.cur >= len(t.code) && !t.more() {
+		return 0
+	}
+	b := t.code[t.cur]
+	if b < utf8.RuneSelf {
+		return rune(b)
+	}
+	if t.cp != nil {
+		if t.cp.isLead(b) && t.cur+1 >= len(t.code) {
+			t.more()
+		}
+		r, _ := t.cp.decodeRune(t.code[t.cur:])
+		return r
+	}
+	if !utf8.FullRune(t.code[t.cur:]) {
+		t.more()
 	}
-	return 0
+	r, _ := utf8.DecodeRune(t.code[t.cur:])
+	return r
 }
 
 func (t *tokenizer) nextRune() rune {
 	if t.cur < len(t.code) {
-		if t.code[t.cur] == '\n' {
+		b := t.code[t.cur]
+		if b == '\n' {
 			t.line++
 			t.col = 1
 		} else {
 			t.col++
 		}
-		t.cur++
+		if b < utf8.RuneSelf {
+			t.cur++
+		} else if t.cp != nil {
+			_, n := t.cp.decodeRune(t.code[t.cur:])
+			t.cur += n
+		} else {
+			_, n := utf8.DecodeRune(t.code[t.cur:])
+			t.cur += n
+		}
 	}
 	return t.currentRune()
 }
@@ -90,50 +90,113 @@ end`)
 	check.Eq(t, tokens[0], token{
 		tokenType: tokenWord,
 		text:      "object",
+		index:     0,
 		line:      1,
 		col:       1,
+		offset:    0,
+		endOffset: 6,
 	})
 	check.Eq(t, tokens[1], token{
 		tokenType: tokenWhiteSpace,
 		text:      " ",
+		index:     6,
 		line:      1,
 		col:       7,
+		offset:    6,
+		endOffset: 7,
 	})
 	check.Eq(t, tokens[2], token{
 		tokenType: tokenWord,
 		text:      "X",
+		index:     7,
 		line:      1,
 		col:       8,
+		offset:    7,
+		endOffset: 8,
 	})
 	check.Eq(t, tokens[3], token{
 		tokenType: tokenWhiteSpace,
 		text:      "\n  ",
+		index:     8,
 		line:      1,
 		col:       9,
+		offset:    8,
+		endOffset: 11,
 	})
 	check.Eq(t, tokens[4], token{
 		tokenType: tokenWord,
 		text:      "Left",
+		index:     11,
 		line:      2,
 		col:       3,
+		offset:    11,
+		endOffset: 15,
 	})
 	check.Eq(t, tokens[5], token{
 		tokenType: tokenWhiteSpace,
 		text:      "\n",
+		index:     15,
 		line:      2,
 		col:       7,
+		offset:    15,
+		endOffset: 16,
 	})
 	check.Eq(t, tokens[6], token{
 		tokenType: tokenWord,
 		text:      "end",
+		index:     16,
 		line:      3,
 		col:       1,
+		offset:    16,
+		endOffset: 19,
 	})
 	check.Eq(t, tokens[7], token{
 		tokenType: tokenEOF,
 		text:      "",
+		index:     19,
 		line:      3,
 		col:       4,
+		offset:    19,
+		endOffset: 19,
+	})
+}
+
+func TestNonASCIITokensAreDecodedFromBytes(t *testing.T) {
+	utf8 := newTokenizer([]byte("äb 'ü'"))
+	check.Eq(t, utf8.next(), token{
+		tokenType: tokenWord, text: "äb",
+		index: 0, line: 1, col: 1, offset: 0, endOffset: 3,
+	})
+	utf8.next()
+	check.Eq(t, utf8.next(), token{
+		tokenType: tokenString, text: "'ü'",
+		index: 4, line: 1, col: 4, offset: 4, endOffset: 8,
+	})
+
+	ansi := newTokenizer([]byte("\xE4b '\xFC'"))
+	ansi.cp = cp1252
+	check.Eq(t, ansi.next(), token{
+		tokenType: tokenWord, text: "äb",
+		index: 0, line: 1, col: 1, offset: 0, endOffset: 2,
+	})
+	ansi.next()
+	check.Eq(t, ansi.next(), token{
+		tokenType: tokenString, text: "'ü'",
+		index: 3, line: 1, col: 4, offset: 3, endOffset: 6,
+	})
+}
+
+func TestInvalidUTF8IsDecodedAsReplacementCharacter(t *testing.T) {
+	tokens := tokenize("'a\xFFb'")
+	check.Eq(t, tokens[0].text, "'a\uFFFDb'")
+}
+
+func TestFindClosingBraceUpdatesPosition(t *testing.T) {
+	lex := newTokenizer([]byte("01\n  ä2}"))
+	check.Eq(t, string(lex.findClosingBrace()), "01\n  ä2")
+	check.Eq(t, lex.next(), token{
+		tokenType: '}', text: "}",
+		index: 8, line: 2, col: 5, offset: 8, endOffset: 9,
 	})
 }
 
@@ -158,7 +221,7 @@ func checkTokens(t *testing.T, code string, want ...token) {
 }
 
 func tokenize(code string) []token {
-	lex := newTokenizer([]rune(code))
+	lex := newTokenizer([]byte(code))
 	var tokens []token
 	for {
 		t := lex.next()
@@ -0,0 +1,226 @@
+package dfm
+
+import "strings"
+
+// Children returns the direct child objects of o, in order.
+func (o *Object) Children() []*Object {
+	var children []*Object
+	for _, prop := range o.Properties {
+		if child, ok := prop.Value.(*Object); ok {
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// Child returns the direct child object with the given name, compared
+// case-insensitively like Delphi does, or nil if there is none.
+func (o *Object) Child(name string) *Object {
+	return findChild(o, name)
+}
+
+// FindObject returns the first object with the given name among all children
+// of o, their children and so on, compared case-insensitively. Objects are
+// searched depth-first, in the order they appear in the code. It returns nil
+// if there is no such object.
+func (o *Object) FindObject(name string) *Object {
+	for _, child := range o.Children() {
+		if child.Name != "" && strings.EqualFold(child.Name, name) {
+			return child
+		}
+		if found := child.FindObject(name); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// Property returns the property of o with the given name, compared
+// case-insensitively, or nil if there is none. Child objects are not
+// returned, use Child for them. The returned Property points into
+// o.Properties, setting its Value changes o.
+func (o *Object) Property(name string) *Property {
+	return findProperty(o, name)
+}
+
+// Get returns the value at the given path. Paths name child objects and
+// properties relative to o, separated by dots. Leading path parts that match
+// child object names descend into these objects, the rest of the path is the
+// property name, which might contain dots itself. For example
+//
+//     form.Get("Panel3.Button1.Font.Height")
+//
+// returns the property Font.Height of Button1, which is a child of Panel3,
+// which is a child of form. Names are compared case-insensitively, like Delphi
+// does. If the path names an object, the value is that *Object. The empty path
+// returns o itself.
+func (o *Object) Get(path string) (PropertyValue, bool) {
+	_, obj, name := lookup(o, path)
+	if name == "" {
+		return obj, true
+	}
+	if prop := findProperty(obj, name); prop != nil {
+		return prop.Value, true
+	}
+	return nil, false
+}
+
+// ParentOf returns the object that has child as a direct child object. It
+// searches the whole tree below o, including o itself. It returns nil if child
+// is not in the tree or if child is o.
+//
+// Objects do not store their parents so they can be moved between trees
+// freely. ParentOf finds the parent instead. To look up the parents of many
+// objects, or without knowing the top-level object, use Parents.
+func (o *Object) ParentOf(child *Object) *Object {
+	path := o.pathTo(child)
+	if len(path) < 2 {
+		return nil
+	}
+	return path[len(path)-2]
+}
+
+// Parents maps child objects to the objects that contain them directly.
+// Top-level objects have no entry. Parents are recorded while parsing if
+// ParseOptions.Parents is set, Object.Parents builds the map for an existing
+// tree:
+//
+//     var parents dfm.Parents
+//     opts := dfm.ParseOptions{Parents: &parents}
+//     form, err := dfm.ParseFileWithOptions(path, opts)
+//     ...
+//     panel := parents.Parent(form.FindObject("Button1"))
+//
+// The map is not updated when the tree changes. Parent and Ancestors ignore
+// entries for objects that were removed from their recorded parent, call
+// Object.Parents again to find the new parents of moved objects.
+type Parents map[*Object]*Object
+
+// Parents returns the parents of all objects in the tree below o.
+func (o *Object) Parents() Parents {
+	p := make(Parents)
+	var add func(obj *Object)
+	add = func(obj *Object) {
+		p.add(obj)
+		for _, child := range obj.Children() {
+			add(child)
+		}
+	}
+	add(o)
+	return p
+}
+
+// Parent returns the object that contains child directly. It returns nil for
+// top-level objects, for objects that are not in the map and for objects that
+// are no longer a child of their recorded parent.
+func (p Parents) Parent(child *Object) *Object {
+	parent := p[child]
+	if parent == nil {
+		return nil
+	}
+	for _, c := range parent.Children() {
+		if c == child {
+			return parent
+		}
+	}
+	return nil
+}
+
+// Ancestors returns the parent of obj, the parent's parent and so on, up to the
+// top-level object.
+func (p Parents) Ancestors(obj *Object) []*Object {
+	var ancestors []*Object
+	for parent := p.Parent(obj); parent != nil; parent = p.Parent(parent) {
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+// add records obj as the parent of its direct children.
+func (p Parents) add(obj *Object) {
+	for _, child := range obj.Children() {
+		p[child] = obj
+	}
+}
+
+// PathOf returns the path of the descendant object, which can be passed to Get
+// to find it again, e.g. "Panel3.Button1". It returns false if the object is
+// not in the tree below o or if an object on the way has no name, since such
+// objects cannot be part of a path. The path of o itself is empty.
+func (o *Object) PathOf(descendant *Object) (string, bool) {
+	objects := o.pathTo(descendant)
+	if objects == nil {
+		return "", false
+	}
+	names := make([]string, 0, len(objects)-1)
+	for _, obj := range objects[1:] {
+		if obj.Name == "" {
+			return "", false
+		}
+		names = append(names, obj.Name)
+	}
+	return strings.Join(names, "."), true
+}
+
+// pathTo returns the objects from o down to target, including both. It returns
+// nil if target is not in the tree.
+func (o *Object) pathTo(target *Object) []*Object {
+	if o == target {
+		return []*Object{o}
+	}
+	for _, child := range o.Children() {
+		if path := child.pathTo(target); path != nil {
+			return append([]*Object{o}, path...)
+		}
+	}
+	return nil
+}
+
+// lookup resolves a path relative to root, see Object.Get for the path syntax. It
+// returns the object that the path leads to, the parent of that object and the
+// remaining property name. The name is empty if the path names an object.
+func lookup(root *Object, path string) (parent, obj *Object, name string) {
+	obj = root
+	if path == "" {
+		return nil, root, ""
+	}
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		child := findChild(obj, part)
+		if child == nil {
+			return parent, obj, strings.Join(parts[i:], ".")
+		}
+		parent, obj = obj, child
+	}
+	return parent, obj, ""
+}
+
+// findChild returns the direct child object of o with the given name, compared
+// case-insensitively, or nil if there is none.
+func findChild(o *Object, name string) *Object {
+	for _, prop := range o.Properties {
+		if child, ok := prop.Value.(*Object); ok && child.Name != "" &&
+			strings.EqualFold(child.Name, name) {
+			return child
+		}
+	}
+	return nil
+}
+
+// findProperty returns the non-object property of o with the given name,
+// compared case-insensitively, or nil if there is none.
+func findProperty(o *Object, name string) *Property {
+	return findPropertyIn(o.Properties, name)
+}
+
+// findPropertyIn is findProperty for the properties of an object or a
+// collection item.
+func findPropertyIn(props []Property, name string) *Property {
+	for i := range props {
+		if _, isObject := props[i].Value.(*Object); !isObject &&
+			strings.EqualFold(props[i].Name, name) {
+			return &props[i]
+		}
+	}
+	return nil
+}
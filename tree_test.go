@@ -0,0 +1,169 @@
+package dfm_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+const treeDFM = `object Form1: TForm1
+  Caption = 'Main'
+  object Panel3: TPanel
+    Align = alTop
+    object Button1: TButton
+      Caption = 'OK'
+      Font.Height = -11
+    end
+    object TMenuItem
+      object Item1: TMenuItem
+      end
+    end
+  end
+  object Button2: TButton
+  end
+end`
+
+func parseTree(t *testing.T) *dfm.Object {
+	t.Helper()
+	obj, err := dfm.ParseString(treeDFM)
+	check.Eq(t, err, nil)
+	return obj
+}
+
+func TestChildren(t *testing.T) {
+	form := parseTree(t)
+	children := form.Children()
+	check.Eq(t, len(children), 2)
+	check.Eq(t, children[0].Name, "Panel3")
+	check.Eq(t, children[1].Name, "Button2")
+	check.Eq(t, len(children[1].Children()), 0)
+
+	check.Eq(t, form.Child("PANEL3"), children[0])
+	check.Eq(t, form.Child("Button1") == nil, true)
+}
+
+func TestFindObject(t *testing.T) {
+	form := parseTree(t)
+	check.Eq(t, form.FindObject("button1").Type, "TButton")
+	check.Eq(t, form.FindObject("Item1").Type, "TMenuItem")
+	check.Eq(t, form.FindObject("Form1") == nil, true)
+	check.Eq(t, form.FindObject("") == nil, true)
+}
+
+func TestPropertyLookup(t *testing.T) {
+	form := parseTree(t)
+	caption := form.Property("caption")
+	check.Eq(t, caption.Value, dfm.String("Main"))
+	caption.Value = dfm.String("Changed")
+	check.Eq(t, form.Properties[0].Value, dfm.String("Changed"))
+	// Child objects are not properties.
+	check.Eq(t, form.Property("Panel3") == nil, true)
+}
+
+func TestGetPath(t *testing.T) {
+	form := parseTree(t)
+	tests := []struct {
+		path string
+		want dfm.PropertyValue
+	}{
+		{"Caption", dfm.String("Main")},
+		{"Panel3.Align", dfm.Identifier("alTop")},
+		{"panel3.button1.font.height", dfm.Int(-11)},
+		{"Panel3.Button1.Caption", dfm.String("OK")},
+	}
+	for _, test := range tests {
+		v, ok := form.Get(test.path)
+		check.Eq(t, ok, true, test.path)
+		check.Eq(t, v, test.want, test.path)
+	}
+
+	v, ok := form.Get("Panel3.Button1")
+	check.Eq(t, ok, true)
+	check.Eq(t, v.(*dfm.Object).Name, "Button1")
+	v, _ = form.Get("")
+	check.Eq(t, v, form)
+
+	_, ok = form.Get("Panel3.Missing")
+	check.Eq(t, ok, false)
+}
+
+func TestParentOfAndPathOf(t *testing.T) {
+	form := parseTree(t)
+	panel := form.FindObject("Panel3")
+	button := form.FindObject("Button1")
+	item := form.FindObject("Item1")
+
+	check.Eq(t, form.ParentOf(button), panel)
+	check.Eq(t, form.ParentOf(panel), form)
+	check.Eq(t, form.ParentOf(form) == nil, true)
+	check.Eq(t, form.ParentOf(&dfm.Object{}) == nil, true)
+	check.Eq(t, form.ParentOf(item).Type, "TMenuItem")
+
+	path, ok := form.PathOf(button)
+	check.Eq(t, ok, true)
+	check.Eq(t, path, "Panel3.Button1")
+	v, _ := form.Get(path)
+	check.Eq(t, v, button)
+
+	path, ok = form.PathOf(form)
+	check.Eq(t, ok, true)
+	check.Eq(t, path, "")
+
+	// Item1 is inside an anonymous object.
+	_, ok = form.PathOf(item)
+	check.Eq(t, ok, false)
+}
+
+func TestParentsAreRecordedWhenParsing(t *testing.T) {
+	checkParents := func(form *dfm.Object, parents dfm.Parents) {
+		t.Helper()
+		panel := form.FindObject("Panel3")
+		button := form.FindObject("Button1")
+		item := form.FindObject("Item1")
+		menu := panel.Children()[1]
+
+		check.Eq(t, parents.Parent(button), panel)
+		check.Eq(t, parents.Parent(panel), form)
+		check.Eq(t, parents.Parent(menu), panel)
+		check.Eq(t, parents.Parent(item), menu)
+		check.Eq(t, parents.Parent(form) == nil, true)
+		check.Eq(t, parents.Parent(&dfm.Object{}) == nil, true)
+		check.Eq(t, parents.Ancestors(item), []*dfm.Object{menu, panel, form})
+		check.Eq(t, len(parents.Ancestors(form)), 0)
+	}
+
+	var parents dfm.Parents
+	form, err := dfm.ParseBytesWithOptions(
+		[]byte(treeDFM),
+		dfm.ParseOptions{Parents: &parents},
+	)
+	check.Eq(t, err, nil)
+	checkParents(form, parents)
+
+	var binary bytes.Buffer
+	check.Eq(t, form.WriteBinaryTo(&binary), nil)
+	parents = nil
+	form, err = dfm.ParseBytesWithOptions(
+		binary.Bytes(),
+		dfm.ParseOptions{Parents: &parents},
+	)
+	check.Eq(t, err, nil)
+	checkParents(form, parents)
+
+	form = parseTree(t)
+	checkParents(form, form.Parents())
+}
+
+func TestParentsIgnoreRemovedChildren(t *testing.T) {
+	form := parseTree(t)
+	parents := form.Parents()
+	panel := form.FindObject("Panel3")
+	button := form.FindObject("Button1")
+
+	panel.Properties = panel.Properties[:1]
+	check.Eq(t, parents.Parent(button) == nil, true)
+	check.Eq(t, len(parents.Ancestors(button)), 0)
+	check.Eq(t, parents.Parent(panel), form)
+}
@@ -0,0 +1,205 @@
+package dfm
+
+import (
+	"strconv"
+)
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of the
+// node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(c *Cursor) (w Visitor)
+}
+
+// Walk traverses the tree below node in depth-first order. It starts by
+// calling v.Visit with a Cursor for node, which is typically the top-level
+// *Object. Then the children of the node are walked:
+//
+//     - the properties of an Object, including child objects
+//     - the values in a Set or Tuple
+//     - the collection items in Items, which in turn have properties
+//
+// The Cursor passed to Visit is only valid during that call.
+func Walk(v Visitor, node PropertyValue) {
+	walk(v, &Cursor{value: node, index: -1})
+}
+
+type inspector func(*Cursor) bool
+
+func (f inspector) Visit(c *Cursor) Visitor {
+	if f(c) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree below node in depth-first order, like Walk. It
+// calls f(c) for each node, if f returns true, Inspect calls f recursively for
+// each of the children of the node, followed by a call of f(nil).
+//
+// For example, this prints all strings of a form:
+//
+//     dfm.Inspect(form, func(c *dfm.Cursor) bool {
+//         if c != nil {
+//             if s, ok := c.Value().(dfm.String); ok {
+//                 fmt.Println(c.Path(), "=", s)
+//             }
+//         }
+//         return true
+//     })
+func Inspect(node PropertyValue, f func(c *Cursor) bool) {
+	Walk(inspector(f), node)
+}
+
+// Cursor describes a node during Walk and Inspect. A node is either the node
+// passed to Walk, a Property of an Object or collection item, a collection item
+// in Items or a value in a Set or Tuple.
+type Cursor struct {
+	path   string
+	value  PropertyValue
+	prop   *Property
+	item   []Property
+	index  int
+	object *Object
+	set    func(PropertyValue)
+}
+
+// Value returns the value of the node. For child objects this is the *Object,
+// for collection items it is nil.
+func (c *Cursor) Value() PropertyValue {
+	return c.value
+}
+
+// Property returns the Property of a property node, which includes child
+// objects. It is nil for other nodes.
+func (c *Cursor) Property() *Property {
+	return c.prop
+}
+
+// Name returns the name of a property node. For child objects it is the
+// object's Name. It is empty for other nodes.
+func (c *Cursor) Name() string {
+	if c.prop == nil {
+		return ""
+	}
+	if obj, ok := c.prop.Value.(*Object); ok {
+		return obj.Name
+	}
+	return c.prop.Name
+}
+
+// Item returns the properties of a collection item node, nil for other nodes.
+func (c *Cursor) Item() []Property {
+	return c.item
+}
+
+// Index returns the index of a collection item in its Items or of a value in
+// its Set or Tuple. It is -1 for other nodes.
+func (c *Cursor) Index() int {
+	return c.index
+}
+
+// Object returns the innermost Object that contains the node. It is nil for
+// the node passed to Walk.
+func (c *Cursor) Object() *Object {
+	return c.object
+}
+
+// Path returns the location of the node relative to the node passed to Walk.
+// Child objects and properties are separated by dots, like in Object.Get, and
+// indexes into Items, Sets and Tuples are written in brackets, e.g.
+//
+//     Panel1.Button1.Caption
+//     DBGrid1.Columns[2].FieldName
+//     Size[0]
+//
+// Anonymous child objects appear with their type. The path of the node passed
+// to Walk is empty.
+func (c *Cursor) Path() string {
+	return c.path
+}
+
+// Replace replaces the value of the node with v. Walk continues with the
+// children of v. Replace panics for collection items and for the node passed
+// to Walk.
+func (c *Cursor) Replace(v PropertyValue) {
+	if c.set == nil {
+		panic("dfm.Cursor.Replace: node cannot be replaced")
+	}
+	c.set(v)
+	c.value = v
+}
+
+func walk(v Visitor, c *Cursor) {
+	w := v.Visit(c)
+	if w == nil {
+		return
+	}
+
+	if c.item != nil {
+		walkProperties(w, c.object, c.path, c.item)
+	}
+	switch val := c.value.(type) {
+	case *Object:
+		walkProperties(w, val, c.path, val.Properties)
+	case Set:
+		walkValues(w, c, val)
+	case Tuple:
+		walkValues(w, c, val)
+	case Items:
+		for i := range val {
+			walk(w, &Cursor{
+				path:   indexPath(c.path, i),
+				item:   val[i],
+				index:  i,
+				object: c.object,
+			})
+		}
+	}
+
+	w.Visit(nil)
+}
+
+func walkProperties(v Visitor, obj *Object, path string, props []Property) {
+	for i := range props {
+		prop := &props[i]
+		name := prop.Name
+		if child, ok := prop.Value.(*Object); ok {
+			name = objectPathName(child)
+		}
+		walk(v, &Cursor{
+			path:   joinPath(path, name),
+			value:  prop.Value,
+			prop:   prop,
+			index:  -1,
+			object: obj,
+			set:    func(v PropertyValue) { prop.Value = v },
+		})
+	}
+}
+
+func walkValues(v Visitor, parent *Cursor, values []PropertyValue) {
+	for i := range values {
+		i := i
+		walk(v, &Cursor{
+			path:   indexPath(parent.path, i),
+			value:  values[i],
+			index:  i,
+			object: parent.object,
+			set:    func(v PropertyValue) { values[i] = v },
+		})
+	}
+}
+
+// objectPathName is the name of obj in paths, which is its type for anonymous
+// objects.
+func objectPathName(obj *Object) string {
+	if obj.Name == "" {
+		return obj.Type
+	}
+	return obj.Name
+}
+
+func indexPath(path string, i int) string {
+	return path + "[" + strconv.Itoa(i) + "]"
+}
@@ -0,0 +1,203 @@
+package dfm_test
+
+import (
+	"testing"
+
+	"github.com/gonutz/check"
+	"github.com/gonutz/dfm"
+)
+
+const walkDFM = `object Form1: TForm1
+  Caption = 'Main'
+  Anchors = [akLeft, akTop]
+  object Grid: TDBGrid
+    Columns = <
+      item
+        FieldName = 'ID'
+        Width = 5
+      end>
+  end
+  object TMenuItem
+    Size = (
+      1
+      2)
+  end
+end`
+
+func TestInspectVisitsAllNodesWithPaths(t *testing.T) {
+	form, err := dfm.ParseString(walkDFM)
+	check.Eq(t, err, nil)
+
+	var paths []string
+	var nils int
+	dfm.Inspect(form, func(c *dfm.Cursor) bool {
+		if c == nil {
+			nils++
+		} else {
+			paths = append(paths, c.Path())
+		}
+		return true
+	})
+	check.Eq(t, paths, []string{
+		"",
+		"Caption",
+		"Anchors",
+		"Anchors[0]",
+		"Anchors[1]",
+		"Grid",
+		"Grid.Columns",
+		"Grid.Columns[0]",
+		"Grid.Columns[0].FieldName",
+		"Grid.Columns[0].Width",
+		"TMenuItem",
+		"TMenuItem.Size",
+		"TMenuItem.Size[0]",
+		"TMenuItem.Size[1]",
+	})
+	check.Eq(t, nils, len(paths))
+}
+
+func TestCursorDescribesNode(t *testing.T) {
+	form, err := dfm.ParseString(walkDFM)
+	check.Eq(t, err, nil)
+	grid := form.Child("Grid")
+
+	cursors := map[string]dfm.Cursor{}
+	dfm.Inspect(form, func(c *dfm.Cursor) bool {
+		if c != nil {
+			cursors[c.Path()] = *c
+		}
+		return true
+	})
+
+	root := cursors[""]
+	check.Eq(t, root.Value(), form)
+	check.Eq(t, root.Object() == nil, true)
+	check.Eq(t, root.Property() == nil, true)
+	check.Eq(t, root.Index(), -1)
+
+	g := cursors["Grid"]
+	check.Eq(t, g.Name(), "Grid")
+	check.Eq(t, g.Value(), grid)
+	check.Eq(t, g.Property(), &form.Properties[2])
+	check.Eq(t, g.Object(), form)
+
+	item := cursors["Grid.Columns[0]"]
+	check.Eq(t, item.Value(), nil)
+	check.Eq(t, item.Index(), 0)
+	check.Eq(t, len(item.Item()), 2)
+	check.Eq(t, item.Object(), grid)
+
+	width := cursors["Grid.Columns[0].Width"]
+	check.Eq(t, width.Name(), "Width")
+	check.Eq(t, width.Value(), dfm.Int(5))
+	check.Eq(t, width.Object(), grid)
+
+	top := cursors["Anchors[1]"]
+	check.Eq(t, top.Name(), "")
+	check.Eq(t, top.Value(), dfm.Identifier("akTop"))
+	check.Eq(t, top.Index(), 1)
+	check.Eq(t, top.Object(), form)
+}
+
+func TestInspectCanSkipSubtrees(t *testing.T) {
+	form, err := dfm.ParseString(walkDFM)
+	check.Eq(t, err, nil)
+
+	var paths []string
+	dfm.Inspect(form, func(c *dfm.Cursor) bool {
+		if c == nil {
+			return false
+		}
+		paths = append(paths, c.Path())
+		_, isObject := c.Value().(*dfm.Object)
+		return c.Path() == "" || !isObject
+	})
+	check.Eq(t, paths, []string{
+		"",
+		"Caption",
+		"Anchors",
+		"Anchors[0]",
+		"Anchors[1]",
+		"Grid",
+		"TMenuItem",
+	})
+}
+
+func TestCursorReplacesNodes(t *testing.T) {
+	form, err := dfm.ParseString(walkDFM)
+	check.Eq(t, err, nil)
+
+	var visited []dfm.PropertyValue
+	dfm.Inspect(form, func(c *dfm.Cursor) bool {
+		if c == nil {
+			return false
+		}
+		switch v := c.Value().(type) {
+		case dfm.String:
+			c.Replace(dfm.String(string(v) + "!"))
+		case dfm.Identifier:
+			if v == "akTop" {
+				c.Replace(dfm.Identifier("akBottom"))
+			}
+		case dfm.Tuple:
+			c.Replace(dfm.Tuple{dfm.Int(3)})
+		case dfm.Int:
+			visited = append(visited, v)
+		}
+		return true
+	})
+
+	v, _ := form.Get("Caption")
+	check.Eq(t, v, dfm.String("Main!"))
+	v, _ = form.Get("Anchors")
+	check.Eq(t, v, dfm.Set{dfm.Identifier("akLeft"), dfm.Identifier("akBottom")})
+	check.Eq(t, form.Child("Grid").Properties[0].Value, dfm.Items{{
+		{Name: "FieldName", Value: dfm.String("ID!")},
+		{Name: "Width", Value: dfm.Int(5)},
+	}})
+	check.Eq(t, form.Properties[3].Value.(*dfm.Object).Properties[0].Value,
+		dfm.Tuple{dfm.Int(3)})
+	// The children of replaced nodes are walked.
+	check.Eq(t, visited, []dfm.PropertyValue{dfm.Int(5), dfm.Int(3)})
+}
+
+func TestRootAndItemsCannotBeReplaced(t *testing.T) {
+	form, err := dfm.ParseString(walkDFM)
+	check.Eq(t, err, nil)
+
+	panics := 0
+	dfm.Inspect(form, func(c *dfm.Cursor) bool {
+		if c != nil && (c.Path() == "" || c.Item() != nil) {
+			func() {
+				defer func() {
+					if recover() != nil {
+						panics++
+					}
+				}()
+				c.Replace(dfm.Int(0))
+			}()
+		}
+		return true
+	})
+	check.Eq(t, panics, 2)
+}
+
+type countVisitor map[string]int
+
+func (v countVisitor) Visit(c *dfm.Cursor) dfm.Visitor {
+	if c != nil {
+		if obj, ok := c.Value().(*dfm.Object); ok {
+			v[obj.Type]++
+		}
+	}
+	return v
+}
+
+func TestWalkCallsVisitor(t *testing.T) {
+	form, err := dfm.ParseString(walkDFM)
+	check.Eq(t, err, nil)
+	count := countVisitor{}
+	dfm.Walk(count, form)
+	check.Eq(t, count, countVisitor{"TForm1": 1, "TDBGrid": 1, "TMenuItem": 1})
+}